@@ -0,0 +1,122 @@
+package mutex
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maximumWaitObjects 是 WaitForMultipleObjects 一次最多能等待的对象数（MAXIMUM_WAIT_OBJECTS）。
+const maximumWaitObjects = 64
+
+// ErrTooManyNames 表明传给 AcquireAny 的 names 超过了一次能够等待的上限。
+var ErrTooManyNames = fmt.Errorf("mutex acquire: at most %d names can be waited at once", maxWorkerWaits)
+
+// AcquireAny 等待 names 对应的跨进程互斥锁中的任意一个，获得其中一个后立即返回。
+// 返回的 int 为获得的锁在 names 中的下标，返回的 Releaser 只释放这一个锁。
+// names 最多为 63 个（MAXIMUM_WAIT_OBJECTS 减去 worker 的唤醒事件），超过时返回 ErrTooManyNames，为空时返回 ErrInvalidName。
+// 同时有多个锁可用时获得下标最小的那个。指向同一个锁的多个名字只等待一次，获得时返回其中最小的下标。
+// 等待期间的事件与错误使用以 | 连接的 names 作为名字，获得之后使用获得的锁的名字。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireAny(names ...string) (*Releaser, int, error) {
+	return acquireAny(names, waitForever)
+}
+
+// AcquireAnyWithTimeout 等待 names 对应的跨进程互斥锁中的任意一个，并指定最长等待时间。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireAnyWithTimeout(timeout time.Duration, names ...string) (*Releaser, int, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquireAny(names, timeout)
+}
+
+func acquireAny(names []string, timeout time.Duration) (*Releaser, int, error) {
+	if len(names) == 0 {
+		return nil, -1, invalidName("", "no names")
+	}
+	if len(names) > maxWorkerWaits {
+		return nil, -1, ErrTooManyNames
+	}
+
+	// unique 是去掉指向同一个锁的重复名字后的 names，orig[i] 为 unique[i] 在 names 中的下标。
+	var unique []string
+	var orig []int
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		name = encodeName(name)
+		if err := validateName(name); err != nil {
+			return nil, -1, err
+		}
+		if key := objectKey(name); !seen[key] {
+			seen[key] = true
+			unique = append(unique, name)
+			orig = append(orig, i)
+		}
+	}
+
+	index := -1
+	f := func(_ string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		r, i, err := anyMutex(unique, timeout, done)
+		if err != nil {
+			return nil, err
+		}
+		index = orig[i]
+		return r, nil
+	}
+	r, err := acquireValid(f, strings.Join(unique, "|"), timeout, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	return r, index, nil
+}
+
+// anyMutex 在 worker 上等待 names 对应的互斥量中的任意一个，返回的 Releaser 的名字为获得的那个。
+//
+// 进程内的锁（见 withLocal）无法与操作系统的锁一起等待。等待开始前不等待地获得 names 中空闲的进程内的锁，
+// 使进程内后来的使用者在它们上面排队；获得一个锁后保留它的进程内的锁（如果获得了的话），释放其余的。
+// 进程内的锁已经被占用的名字同样参与等待，互斥依然由操作系统的锁保证。
+// 持有者记录（见 openOwnerSlot）在等待期间为每个名字打开，获得后只保留获得的那个。
+func anyMutex(names []string, timeout time.Duration, done <-chan struct{}) (*Releaser, int, error) {
+	locals := make([]chan struct{}, len(names))
+	slots := make([]*ownerSlot, len(names))
+	for i, name := range names {
+		ch := localLock(name)
+		select {
+		case ch <- struct{}{}:
+			locals[i] = ch
+		default:
+		}
+		slots[i] = openOwnerSlot(name, nil)
+	}
+	// cleanup 释放除第 keep 个以外的进程内的锁与持有者记录。keep 为 -1 时全部释放。
+	cleanup := func(keep int) {
+		for i := range names {
+			if i == keep {
+				continue
+			}
+			if locals[i] != nil {
+				<-locals[i]
+			}
+			slots[i].close()
+		}
+	}
+
+	r, i, err := acquireAnyObject(mutexObject, names, timeout, done)
+	if err != nil {
+		cleanup(-1)
+		return nil, -1, err
+	}
+	cleanup(i)
+	r.name = names[i]
+	slots[i].own(r)
+	if ch := locals[i]; ch != nil {
+		release := r.release
+		r.release = func() error {
+			err := release()
+			<-ch
+			return err
+		}
+	}
+	return r, i, nil
+}
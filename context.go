@@ -0,0 +1,20 @@
+package mutex
+
+import "context"
+
+// lockContextKey 是在 context 中保存 Releaser 的键。
+type lockContextKey struct{}
+
+// ContextWithLock 返回保存了 r 的 ctx 的副本，使下游代码可以通过 LockFromContext 得知当前持有的锁，例如在结构化日志中记录锁名。
+// 它只是附带的信息，对加锁没有任何影响：锁的生命周期与 ctx 无关，ctx 被取消时锁不会被释放，锁被释放后 ctx 中依然保存着 r。
+// 同一个 ctx 中再次调用时，新的 r 覆盖旧的。
+func ContextWithLock(ctx context.Context, r *Releaser) context.Context {
+	return context.WithValue(ctx, lockContextKey{}, r)
+}
+
+// LockFromContext 返回 ContextWithLock 保存在 ctx 中的 Releaser。ctx 中没有保存时返回 false。
+// 返回的 Releaser 可能已经被释放了，调用者只应读取它的锁名等信息，释放锁依然是获得锁的一方的责任。
+func LockFromContext(ctx context.Context) (*Releaser, bool) {
+	r, ok := ctx.Value(lockContextKey{}).(*Releaser)
+	return r, ok && r != nil
+}
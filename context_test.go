@@ -0,0 +1,33 @@
+package mutex
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextWithLock(t *testing.T) {
+	if _, ok := LockFromContext(context.Background()); ok {
+		t.Fatal("expect no lock in background context")
+	}
+
+	name := testName("context_with_lock")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(ContextWithLock(context.Background(), r))
+	got, ok := LockFromContext(ctx)
+	if !ok || got != r || got.Name() != name {
+		t.Fatalf("expect %p, got %p, %v", r, got, ok)
+	}
+
+	// 取消 ctx 不影响锁。
+	cancel()
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := LockFromContext(ContextWithLock(context.Background(), nil)); ok {
+		t.Fatal("expect no lock for nil Releaser")
+	}
+}
@@ -0,0 +1,93 @@
+package mutex
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// eventSuffix 是事件的内核对象名的后缀，使同名的互斥锁与事件成为两个独立的对象，而不是相互冲突。
+const eventSuffix = "#event"
+
+// NamedEvent 是跨进程的具名事件，用于生产者与消费者之间的通知，而不是互斥。
+// 事件与互斥量不同，不属于任何线程，因此它不需要 worker 线程，所有方法都可以在任意协程中并发调用。
+// 事件对象在所有句柄关闭后被销毁，在此之前发出的通知会被保留，因此通信的双方都应该在通信期间保持 NamedEvent 打开。
+// 不再使用时调用 Close 关闭句柄。
+type NamedEvent struct {
+	name string
+	h    windows.Handle
+}
+
+// NewEvent 创建或打开名为 name 的具名事件，新建的事件处于未触发状态。
+// 实际的内核对象名为 name + "#event"，因此它与同名的互斥锁互不影响；与其他程序共享事件时应使用这个名字。
+// manualReset 为 true 时事件被触发后一直保持触发状态，唤醒所有等待者，直到调用 Reset；
+// 为 false 时事件在唤醒一个等待者后自动恢复为未触发状态。
+// manualReset 只在事件第一次被创建时生效，之后打开同名事件时沿用已有的模式。
+func NewEvent(name string, manualReset bool) (*NamedEvent, error) {
+	return newEvent(encodeName(name), manualReset)
+}
+
+// newEvent 与 NewEvent 相同，但 name 已经经过了 encodeName 的转换。
+func newEvent(name string, manualReset bool) (*NamedEvent, error) {
+	if err := validateName(name + eventSuffix); err != nil {
+		return nil, err
+	}
+	var manual uint32
+	if manualReset {
+		manual = 1
+	}
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createeventw
+	h, err := windows.CreateEvent(nil, manual, 0, windows.StringToUTF16Ptr(name+eventSuffix))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return nil, nameError(name, createFailed(name, err))
+	}
+	return &NamedEvent{name: name, h: h}, nil
+}
+
+// Name 返回创建事件时使用的名字，不包含 "#event" 后缀。
+func (e *NamedEvent) Name() string {
+	return e.name
+}
+
+// Signal 触发事件。
+func (e *NamedEvent) Signal() error {
+	if err := windows.SetEvent(e.h); err != nil {
+		return nameError(e.name, err)
+	}
+	return nil
+}
+
+// Reset 将事件恢复为未触发状态。
+func (e *NamedEvent) Reset() error {
+	if err := windows.ResetEvent(e.h); err != nil {
+		return nameError(e.name, err)
+	}
+	return nil
+}
+
+// Wait 等待事件被触发，等待超过 timeout 时返回 ErrWaitTimeout。timeout 没有上限，小于 0 时视为 0。
+// 自动重置的事件在唤醒这一次等待后就恢复为未触发状态。
+func (e *NamedEvent) Wait(timeout time.Duration) error {
+	if timeout < 0 {
+		timeout = 0
+	}
+	rt, err := waitDeadline(e.h, time.Now().Add(timeout))
+	switch rt {
+	case windows.WAIT_OBJECT_0:
+		return nil
+	case uint32(windows.WAIT_TIMEOUT):
+		err = errWaitTimeout
+	case windows.WAIT_FAILED:
+		err = waitFailed(err)
+	default:
+		err = unexpectedWait(rt)
+	}
+	return nameError(e.name, err)
+}
+
+// Close 关闭事件的句柄。之后不能再使用 e。
+func (e *NamedEvent) Close() error {
+	return windows.CloseHandle(e.h)
+}
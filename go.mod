@@ -1,5 +1,5 @@
 module github.com/kvii/mutex
 
-go 1.19
+go 1.20
 
 require golang.org/x/sys v0.18.0
@@ -0,0 +1,42 @@
+package mutex
+
+import (
+	"errors"
+	"time"
+)
+
+// WithLock 获取名为 name 的跨进程互斥锁，在持有锁的情况下运行 fn，然后释放锁。
+// 即使 fn 发生 panic 锁也会被释放，之后 panic 继续向上传播。
+// fn 的错误与释放锁的错误通过 errors.Join 合并后返回；获取锁失败时 fn 不会被调用。
+func WithLock(name string, fn func() error) error {
+	return WithLockDetail(name, func(*Releaser) error { return fn() })
+}
+
+// WithLockTimeout 与 WithLock 相同，但指定了获取锁的最长等待时间。
+func WithLockTimeout(name string, timeout time.Duration, fn func() error) error {
+	r, err := AcquireWithTimeout(name, timeout)
+	if err != nil {
+		return err
+	}
+	return runLocked(r, func(*Releaser) error { return fn() })
+}
+
+// WithLockDetail 与 WithLock 相同，但将持有的 Releaser 传给 fn，便于检查 IsAbandoned 等信息。
+// fn 不应调用 r 的 Release 或 Close，锁由 WithLockDetail 负责释放。
+func WithLockDetail(name string, fn func(r *Releaser) error) error {
+	r, err := Acquire(name)
+	if err != nil {
+		return err
+	}
+	return runLocked(r, fn)
+}
+
+// runLocked 运行 fn 后释放 r，fn 发生 panic 时同样释放。
+func runLocked(r *Releaser, fn func(r *Releaser) error) (err error) {
+	defer func() {
+		if e := r.Release(); e != nil {
+			err = errors.Join(err, e)
+		}
+	}()
+	return fn(r)
+}
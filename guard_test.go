@@ -0,0 +1,45 @@
+package mutex
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithLock(t *testing.T) {
+	name := testName("with_lock")
+	errFn := errors.New("fn")
+
+	err := WithLock(name, func() error {
+		if r, err := AcquireWithTimeout(name, 0); !errors.Is(err, ErrWaitTimeout) {
+			if err == nil {
+				_ = r.Release()
+			}
+			t.Fatalf("expect ErrWaitTimeout, got %v", err)
+		}
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Fatalf("expect errFn, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expect panic")
+			}
+		}()
+		_ = WithLockTimeout(name, time.Second, func() error { panic("fn") })
+	}()
+
+	// panic 之后锁已经被释放了。
+	err = WithLockDetail(name, func(r *Releaser) error {
+		if r.Name() != name {
+			t.Fatalf("expect %q, got %q", name, r.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
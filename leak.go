@@ -0,0 +1,52 @@
+package mutex
+
+import (
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+// LeakHandler 在 Releaser 未被释放就被垃圾回收时调用。调用结束后 Releaser 会被尽力释放。
+type LeakHandler func(r *Releaser)
+
+var (
+	leakDetection atomic.Bool
+	leakHandler   atomic.Pointer[LeakHandler]
+)
+
+// SetLeakDetection 开启或关闭泄漏检测，默认关闭。
+// 开启后，此后获得的 Releaser 如果在没有调用 Release 的情况下被垃圾回收，
+// 会先调用 SetLeakHandler 设置的处理函数（默认打印日志），再尽力释放锁资源。
+// 垃圾回收的时机是不确定的，泄漏检测只是兜底手段，不能代替 Release。
+func SetLeakDetection(enabled bool) {
+	leakDetection.Store(enabled)
+}
+
+// SetLeakHandler 设置泄漏检测的处理函数。传入 nil 时恢复为默认的日志输出。
+// 处理函数在垃圾回收器的 finalizer 协程中调用，不应阻塞。
+func SetLeakHandler(h LeakHandler) {
+	if h == nil {
+		leakHandler.Store(nil)
+		return
+	}
+	leakHandler.Store(&h)
+}
+
+// trackLeak 在开启泄漏检测时为 r 设置 finalizer。
+func trackLeak(r *Releaser) {
+	if leakDetection.Load() {
+		runtime.SetFinalizer(r, finalizeReleaser)
+	}
+}
+
+func finalizeReleaser(r *Releaser) {
+	if r.released.Load() {
+		return
+	}
+	if h := leakHandler.Load(); h != nil {
+		(*h)(r)
+	} else {
+		log.Printf("mutex: releaser of %q garbage collected without Release, releasing it now", r.name)
+	}
+	_ = r.Release()
+}
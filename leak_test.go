@@ -0,0 +1,48 @@
+package mutex
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLeakDetection(t *testing.T) {
+	name := testName("leak_detection")
+
+	leaked := make(chan struct{}, 1)
+	SetLeakDetection(true)
+	SetLeakHandler(func(r *Releaser) {
+		select {
+		case leaked <- struct{}{}:
+		default:
+		}
+	})
+	t.Cleanup(func() {
+		SetLeakDetection(false)
+		SetLeakHandler(nil)
+	})
+
+	func() {
+		if _, err := Acquire(name); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		runtime.GC()
+		select {
+		case <-leaked:
+			done = true
+		case <-timeout:
+			t.Fatal("finalizer not fired")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	r, err := AcquireWithTimeout(name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
@@ -0,0 +1,245 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrInvalidTTL 表明 AcquireLease 的 ttl 不大于 0。
+	ErrInvalidTTL = errors.New("mutex lease: ttl must be positive")
+	// ErrLeaseLost 表明租约在释放之前就失效了：续期中断超过了 ttl，其他使用者已经把它当作被遗弃的租约接管了。
+	ErrLeaseLost = errors.New("mutex lease: lost")
+)
+
+// 租约由一条记录与保护它的互斥锁组成，它们的名字由 name 加后缀得到。
+// 记录保存着持有者的令牌与最近一次续期的时间，读写记录时短暂地持有互斥锁，而不是在整个租约期间持有它。
+// 因此挂起而没有退出的持有者不会使锁被永久占用：它停止续期超过 ttl 后，其他使用者就可以接管记录。
+const (
+	leaseSuffix       = "#lease"
+	leaseRecordSuffix = "#lease.record"
+)
+
+// leaseTokens 为当前进程的租约生成不同的令牌。
+var leaseTokens atomic.Uint32
+
+// Lease 是 AcquireLease 获得的租约。
+type Lease struct {
+	name      string
+	ttl       time.Duration
+	token     uint64
+	record    *leaseRecord
+	abandoned bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+	lost     chan struct{}
+	released atomic.Bool
+}
+
+// AcquireLease 获取名为 name 的租约，一直等待到获得为止。租约与同名的互斥锁互不影响。
+// 获得租约后，后台协程每隔 ttl/3 续期一次，即把当前时间写入共享的记录；其他使用者发现记录超过 ttl 没有续期时，
+// 把持有者视为已经崩溃或挂起，直接接管租约，此时 Lease.IsAbandoned 返回 true。
+// 与互斥锁的遗弃检测不同，它也能发现挂起而没有退出的持有者。ttl 不大于 0 时返回 ErrInvalidTTL。
+//
+// 续期时间使用墙上时钟，所有使用者必须在同一台机器上。系统时钟向前跳变超过 ttl 时租约可能被提前接管，向后跳变则会推迟接管。
+// 持有者被暂停（例如进程被挂起或长时间的 GC 停顿）超过 ttl 时，租约同样可能在它不知情的情况下被接管，
+// 持有者应通过 Lost 得知租约已经失效，并停止访问被保护的资源。ttl 应远大于可能的停顿。
+// windows 下记录保存在共享内存中，所有使用者都关闭它之后记录随之销毁，之后的使用者会看到一个空闲的租约。
+// 返回 Lease 的 Release 方法用于释放租约。它必须且只能被调用一次。
+func AcquireLease(name string, ttl time.Duration) (*Lease, error) {
+	return acquireLease(name, ttl, waitForever, nil)
+}
+
+// AcquireLeaseWithTimeout 与 AcquireLease 相同，但指定最长等待时间，超时时返回 ErrWaitTimeout。timeout 小于 0 时视为 0。
+func AcquireLeaseWithTimeout(name string, ttl, timeout time.Duration) (*Lease, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquireLease(name, ttl, timeout, nil)
+}
+
+// AcquireLeaseContext 与 AcquireLease 相同，但在 ctx 被取消或超时时放弃等待，返回 ctx.Err()。
+func AcquireLeaseContext(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	l, err := acquireLease(name, ttl, waitForever, ctx.Done())
+	if errors.Is(err, ErrCanceled) {
+		return nil, ctx.Err()
+	}
+	return l, err
+}
+
+// acquireLease 轮询租约的记录直到获得租约。timeout 为 waitForever 时无限等待；done 被关闭时放弃等待并返回 ErrCanceled。
+func acquireLease(name string, ttl, timeout time.Duration, done <-chan struct{}) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	name = encodeName(name)
+	if err := validateName(name + leaseRecordSuffix); err != nil {
+		return nil, err
+	}
+	record, err := openLeaseRecord(name + leaseRecordSuffix)
+	if err != nil {
+		return nil, nameError(name, err)
+	}
+
+	l := &Lease{
+		name:    name,
+		ttl:     ttl,
+		token:   uint64(os.Getpid())<<32 | uint64(leaseTokens.Add(1)),
+		record:  record,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+	poll := defaultPollSlice
+	if poll > ttl/2 {
+		poll = ttl / 2
+	}
+	var deadline time.Time
+	if timeout != waitForever {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		ok, err := l.tryTake()
+		if err == nil && !ok && !deadline.IsZero() && !time.Now().Before(deadline) {
+			err = ErrWaitTimeout
+		}
+		if err != nil {
+			record.close()
+			return nil, nameError(name, err)
+		}
+		if ok {
+			break
+		}
+		d := jitter(poll)
+		if rest := time.Until(deadline); !deadline.IsZero() && rest < d {
+			d = rest
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-done:
+			t.Stop()
+			record.close()
+			return nil, nameError(name, ErrCanceled)
+		}
+	}
+	go l.renew()
+	return l, nil
+}
+
+// tryTake 在记录空闲或已经过期时将它记录为 l 所有。
+func (l *Lease) tryTake() (bool, error) {
+	var ok bool
+	err := l.withRecord(func(token uint64, heartbeat time.Time) (uint64, bool) {
+		if token != 0 && time.Since(heartbeat) <= l.ttl {
+			return 0, false
+		}
+		ok = true
+		l.abandoned = token != 0
+		return l.token, true
+	})
+	return ok, err
+}
+
+// withRecord 在保护记录的锁中读取记录并调用 f。f 返回的 bool 为 true 时，把它返回的令牌与当前时间写回记录，令牌为 0 时清空记录。
+func (l *Lease) withRecord(f func(token uint64, heartbeat time.Time) (next uint64, write bool)) error {
+	g, err := localAcquire(l.name+leaseSuffix, waitForever, nil)
+	if err != nil {
+		return err
+	}
+	defer g.Release()
+
+	token, heartbeat, err := l.record.load()
+	if err != nil {
+		return err
+	}
+	next, write := f(token, time.Unix(0, heartbeat))
+	switch {
+	case !write:
+		return nil
+	case next == 0:
+		return l.record.store(0, 0)
+	}
+	return l.record.store(next, time.Now().UnixNano())
+}
+
+// renew 定期续期，直到 l 被释放或租约失效。
+func (l *Lease) renew() {
+	defer close(l.stopped)
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+		var taken bool
+		err := l.withRecord(func(token uint64, _ time.Time) (uint64, bool) {
+			taken = token != l.token
+			return l.token, !taken
+		})
+		if err == nil && !taken {
+			last = time.Now()
+			continue
+		}
+		if taken || time.Since(last) > l.ttl {
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// stopRenewing 停止续期并等待续期的协程退出。
+func (l *Lease) stopRenewing() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.stopped
+}
+
+// Name 返回获取租约时使用的名字。
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// IsAbandoned 表明租约是否是从停止续期的上一任持有者那里接管的。
+func (l *Lease) IsAbandoned() bool {
+	return l.abandoned
+}
+
+// Lost 返回的 channel 在租约失效时被关闭：其他使用者接管了租约，或者续期持续失败超过了 ttl。
+// 此后持有者不应再访问被保护的资源。正常释放时它不会被关闭。
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release 停止续期并释放租约。租约已经失效时返回 ErrLeaseLost，重复调用返回 ErrAlreadyReleased。
+func (l *Lease) Release() error {
+	if !l.released.CompareAndSwap(false, true) {
+		return ErrAlreadyReleased
+	}
+	l.stopRenewing()
+	defer l.record.close()
+
+	var lost bool
+	err := l.withRecord(func(token uint64, _ time.Time) (uint64, bool) {
+		lost = token != l.token
+		return 0, !lost
+	})
+	if err != nil {
+		return nameError(l.name, err)
+	}
+	if lost {
+		return nameError(l.name, ErrLeaseLost)
+	}
+	return nil
+}
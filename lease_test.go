@@ -0,0 +1,106 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireLease(t *testing.T) {
+	name := testName("acquire_lease")
+	if _, err := AcquireLease(name, 0); !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("expect ErrInvalidTTL, got %v", err)
+	}
+
+	l, err := AcquireLease(name, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.IsAbandoned() {
+		t.Fatal("expect not abandoned")
+	}
+
+	// 续期中的租约不会被接管。
+	ch := make(chan *Lease, 1)
+	go func() {
+		l2, err := AcquireLease(name, 300*time.Millisecond)
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- l2
+	}()
+	select {
+	case <-ch:
+		t.Fatal("expect waiting for the lease")
+	case <-time.After(time.Second):
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+	l2 := <-ch
+	if l2 == nil {
+		return
+	}
+	if l2.IsAbandoned() {
+		t.Fatal("expect not abandoned")
+	}
+	if err := l2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLeaseStale(t *testing.T) {
+	name := testName("acquire_lease_stale")
+	ttl := 200 * time.Millisecond
+
+	l, err := AcquireLease(name, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟挂起的持有者：停止续期，但不释放。
+	l.stopRenewing()
+
+	l2, err := AcquireLease(name, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l2.IsAbandoned() {
+		t.Fatal("expect abandoned")
+	}
+
+	// 续期的协程已经停止，无法通过 Lost 得知租约失效，但 Release 会报告它。
+	if err := l.Release(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expect ErrLeaseLost, got %v", err)
+	}
+	select {
+	case <-l2.Lost():
+		t.Fatal("expect the new lease to be held")
+	default:
+	}
+	if err := l2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLeaseWithTimeout(t *testing.T) {
+	name := testName("acquire_lease_with_timeout")
+	l, err := AcquireLease(name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	if _, err := AcquireLeaseWithTimeout(name, time.Second, 50*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := AcquireLeaseContext(ctx, name, time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import (
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+// leaseRecord 是保存在记录文件中的租约记录，文件的内容是两个小端序的 64 位整数：令牌与最近一次续期的 UnixNano。
+// 读写由租约的互斥锁保护。
+type leaseRecord struct {
+	fd int
+}
+
+// openLeaseRecord 打开名为 name 的记录文件，文件不存在时创建它。
+func openLeaseRecord(name string) (*leaseRecord, error) {
+	fd, _, err := openLockFile(lockPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return &leaseRecord{fd: fd}, nil
+}
+
+// load 读取记录。空的记录表示租约空闲。
+func (r *leaseRecord) load() (token uint64, heartbeat int64, err error) {
+	var buf [16]byte
+	n, err := unix.Pread(r.fd, buf[:], 0)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	if n < len(buf) {
+		return 0, 0, nil
+	}
+	return binary.LittleEndian.Uint64(buf[:8]), int64(binary.LittleEndian.Uint64(buf[8:])), nil
+}
+
+// store 写入记录。
+func (r *leaseRecord) store(token uint64, heartbeat int64) error {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], token)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(heartbeat))
+	_, err := unix.Pwrite(r.fd, buf[:], 0)
+	return err
+}
+
+// close 关闭记录文件。
+func (r *leaseRecord) close() {
+	unix.Close(r.fd)
+}
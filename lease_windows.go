@@ -0,0 +1,52 @@
+package mutex
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// leaseRecord 是映射到当前进程的共享内存中的租约记录：令牌与最近一次续期的 UnixNano。读写由租约的互斥锁保护。
+type leaseRecord struct {
+	h    windows.Handle
+	data *[2]uint64
+}
+
+// openLeaseRecord 创建或打开名为 name 的共享内存。
+func openLeaseRecord(name string) (*leaseRecord, error) {
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-createfilemappingw
+	h, err := windows.CreateFileMapping(windows.InvalidHandle, nil, windows.PAGE_READWRITE, 0, 16, windows.StringToUTF16Ptr(name))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return nil, createFailed(name, err)
+	}
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-mapviewoffile
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_WRITE, 0, 0, 16)
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	// 见 openOwnerSlot。
+	return &leaseRecord{h: h, data: (*[2]uint64)(*(*unsafe.Pointer)(unsafe.Pointer(&addr)))}, nil
+}
+
+// load 读取记录。新建的共享内存全部为零，表示租约空闲。
+func (r *leaseRecord) load() (token uint64, heartbeat int64, err error) {
+	return atomic.LoadUint64(&r.data[0]), int64(atomic.LoadUint64(&r.data[1])), nil
+}
+
+// store 写入记录。
+func (r *leaseRecord) store(token uint64, heartbeat int64) error {
+	atomic.StoreUint64(&r.data[0], token)
+	atomic.StoreUint64(&r.data[1], uint64(heartbeat))
+	return nil
+}
+
+// close 解除映射并关闭句柄。
+func (r *leaseRecord) close() {
+	_ = windows.UnmapViewOfFile(uintptr(unsafe.Pointer(r.data)))
+	windows.CloseHandle(r.h)
+}
@@ -0,0 +1,25 @@
+package mutex
+
+import "sort"
+
+// ListNames 列出当前进程可见的、名字以 prefix 开头的跨进程互斥锁的锁名，按字典序排列，用于运维工具查看系统中存在哪些锁。
+// 它只用于诊断，与加锁无关：列出的锁可能已经被其他进程持有或释放，结果只是调用时的快照，是尽力而为的。
+// 返回的是实际使用的锁名，即经过 SetNameEncoder 设置的编码器转换后的结果，prefix 也按实际的锁名匹配，区分大小写。
+//
+// windows 下 prefix 开头的命名空间前缀（Global\、Local\、Session\<id>\）决定列出哪个对象目录，没有前缀时列出当前会话的对象目录，
+// 返回的锁名带有与 prefix 相同的命名空间前缀，可以直接传给 Acquire 等函数。
+// 结果只包含当前至少被一个进程打开的互斥量，其中也包括读写锁与租约等伴生的互斥量（例如 name + "#rw.gate" 与 name + "#lease"）。
+// 枚举通过 NtOpenDirectoryObject 与 NtQueryDirectoryObject 完成，需要对对象目录的 DIRECTORY_QUERY 权限，
+// 普通用户通常可以列出自己会话的目录与 Global\，其他会话的目录一般需要管理员权限，没有权限时返回的错误包装了 windows.ERROR_ACCESS_DENIED。
+//
+// unix 下列出锁文件目录中本包创建的锁文件。锁文件在释放后依然保留，因此结果包括曾经使用过、目前没有被持有的锁，
+// 直到它们被 Purge 删除。名字过长的锁以哈希值命名锁文件，无法还原出锁名，结果中出现的是这个哈希值。
+// 其他平台返回 ErrUnsupportedPlatform。
+func ListNames(prefix string) ([]string, error) {
+	names, err := osListNames(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
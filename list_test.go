@@ -0,0 +1,40 @@
+package mutex
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestListNames(t *testing.T) {
+	a, b := testName("list_names_a"), testName("list_names_b")
+	ra, err := Acquire(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Release()
+	rb, err := Acquire(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Release()
+
+	names, err := ListNames("kvii_mutex_test_list_names_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{a, b} {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			t.Fatalf("expect %q in %v", name, names)
+		}
+	}
+
+	names, err = ListNames(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if name == b {
+			t.Fatalf("expect %q not in %v", b, names)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// osListNames 还原锁文件目录中锁文件的锁名，见 lockPath。
+func osListNames(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(lockDir())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := parseLockFileName(e.Name())
+		if !ok || isCompanion(name) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// isCompanion 表明 name 是否是伴生文件的名字，见 companionSuffixes。
+func isCompanion(name string) bool {
+	for _, suffix := range companionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLockFileName 从锁文件名中还原锁名。fileName 不是 lockPath 为还原出的锁名生成的文件名时返回 false，
+// 例如以哈希值命名的锁文件，或是其他程序创建的文件。
+func parseLockFileName(fileName string) (string, bool) {
+	s, ok := strings.CutPrefix(fileName, "kvii_mutex_")
+	if !ok {
+		return "", false
+	}
+	if s, ok = strings.CutSuffix(s, ".lock"); !ok {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", false
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	name := b.String()
+	if filepath.Base(lockPath(name)) != fileName {
+		return "", false
+	}
+	return name, true
+}
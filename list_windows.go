@@ -0,0 +1,128 @@
+package mutex
+
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modntdll                   = windows.NewLazySystemDLL("ntdll.dll")
+	procNtOpenDirectoryObject  = modntdll.NewProc("NtOpenDirectoryObject")
+	procNtQueryDirectoryObject = modntdll.NewProc("NtQueryDirectoryObject")
+)
+
+// directoryQuery 是 DIRECTORY_QUERY 访问权限。
+const directoryQuery = 0x0001
+
+// objectDirectoryInformation 对应 OBJECT_DIRECTORY_INFORMATION。
+type objectDirectoryInformation struct {
+	Name     windows.NTUnicodeString
+	TypeName windows.NTUnicodeString
+}
+
+// osListNames 列出 prefix 的命名空间前缀所对应的对象目录中名字以 prefix 开头的互斥量。
+func osListNames(prefix string) ([]string, error) {
+	rest := trimNamespace(prefix)
+	ns := prefix[:len(prefix)-len(rest)]
+	dir, err := objectDirectory(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := openDirectoryObject(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(h)
+
+	var names []string
+	err = queryDirectoryObject(h, func(name, typeName string) {
+		if typeName == "Mutant" && strings.HasPrefix(name, rest) {
+			names = append(names, ns+name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// objectDirectory 返回命名空间前缀 ns 对应的对象目录。
+// https://learn.microsoft.com/zh-cn/windows/win32/termserv/kernel-object-namespaces
+func objectDirectory(ns string) (string, error) {
+	var session uint32
+	switch {
+	case hasPrefixFold(ns, globalPrefix):
+		return `\BaseNamedObjects`, nil
+	case hasPrefixFold(ns, sessionPrefix):
+		id, err := strconv.ParseUint(strings.TrimSuffix(ns[len(sessionPrefix):], `\`), 10, 32)
+		if err != nil {
+			return "", invalidName(ns, "invalid session id")
+		}
+		session = uint32(id)
+	default:
+		if err := windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &session); err != nil {
+			return "", err
+		}
+	}
+	if session == 0 {
+		return `\BaseNamedObjects`, nil
+	}
+	return `\Sessions\` + strconv.FormatUint(uint64(session), 10) + `\BaseNamedObjects`, nil
+}
+
+// https://learn.microsoft.com/zh-cn/windows/win32/devnotes/ntopendirectoryobject
+func openDirectoryObject(dir string) (windows.Handle, error) {
+	name, err := windows.NewNTUnicodeString(dir)
+	if err != nil {
+		return 0, err
+	}
+	oa := windows.OBJECT_ATTRIBUTES{ObjectName: name, Attributes: windows.OBJ_CASE_INSENSITIVE}
+	oa.Length = uint32(unsafe.Sizeof(oa))
+	var h windows.Handle
+	r, _, _ := procNtOpenDirectoryObject.Call(uintptr(unsafe.Pointer(&h)), directoryQuery, uintptr(unsafe.Pointer(&oa)))
+	if r != 0 {
+		return 0, windows.NTStatus(r).Errno()
+	}
+	return h, nil
+}
+
+// queryDirectoryObject 对对象目录 h 中的每个对象调用 f。
+// https://learn.microsoft.com/zh-cn/windows/win32/devnotes/ntquerydirectoryobject
+func queryDirectoryObject(h windows.Handle, f func(name, typeName string)) error {
+	buf := make([]byte, 64*1024)
+	var context, length uint32
+	restart := uintptr(1)
+	for {
+		r, _, _ := procNtQueryDirectoryObject.Call(
+			uintptr(h),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			0, // ReturnSingleEntry
+			restart,
+			uintptr(unsafe.Pointer(&context)),
+			uintptr(unsafe.Pointer(&length)),
+		)
+		switch status := windows.NTStatus(r); status {
+		case windows.STATUS_SUCCESS, windows.STATUS_MORE_ENTRIES:
+		case windows.STATUS_NO_MORE_ENTRIES:
+			return nil
+		default:
+			return status.Errno()
+		}
+		restart = 0
+
+		// 缓冲区开头是以全零的条目结尾的 OBJECT_DIRECTORY_INFORMATION 数组，名字的字符串位于其后。
+		size := unsafe.Sizeof(objectDirectoryInformation{})
+		for off := uintptr(0); off+size <= uintptr(len(buf)); off += size {
+			info := (*objectDirectoryInformation)(unsafe.Pointer(&buf[off]))
+			if info.Name.Length == 0 {
+				break
+			}
+			f(info.Name.String(), info.TypeName.String())
+		}
+	}
+}
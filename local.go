@@ -0,0 +1,199 @@
+package mutex
+
+import (
+	"sync"
+	"time"
+)
+
+// 同一进程内对同名锁的竞争先在进程内的锁上排队，只有排在最前面的使用者才会去等待操作系统的锁，
+// 其余的使用者在用户态等待，不会各自阻塞在系统调用上（windows 下也不会各自占用一个 worker 线程）。
+// 跨进程的行为不变：进程内的锁只是在操作系统的锁之外多加了一层。
+//
+// 进程内的锁需要支持超时与取消，因此使用容量为 1 的 channel 而不是 sync.Mutex。
+// 进程内的锁以 objectKey 区分，指向同一个操作系统对象的不同写法（例如 windows 下的 x 与 Local\x）共用一个锁。
+// 条目不会被删除，每个用过的 name 占用一个很小的 channel。
+var localLocks sync.Map // map[string]chan struct{}
+
+// localLock 返回 name 在进程内的锁。
+func localLock(name string) chan struct{} {
+	key := objectKey(name)
+	if ch, ok := localLocks.Load(key); ok {
+		return ch.(chan struct{})
+	}
+	ch, _ := localLocks.LoadOrStore(key, make(chan struct{}, 1))
+	return ch.(chan struct{})
+}
+
+// withLocal 返回先获得进程内的锁、再调用 f 获得操作系统的锁的 acquireFunc。
+// 等待进程内的锁所花的时间计入 timeout。释放时先释放操作系统的锁，再释放进程内的锁。
+func withLocal(f acquireFunc) acquireFunc {
+	return func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		ch := localLock(name)
+		start := time.Now()
+		if err := lockLocal(name, ch, timeout, done); err != nil {
+			return nil, err
+		}
+		if timeout != waitForever {
+			timeout -= time.Since(start)
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+
+		r, err := f(name, timeout, done)
+		if err != nil {
+			<-ch
+			return nil, err
+		}
+		release := r.release
+		r.release = func() error {
+			err := release()
+			<-ch
+			return err
+		}
+		return r, nil
+	}
+}
+
+// lockLocal 获得进程内的锁 ch。无法立即获得而需要等待时，等待期间计入 WaitersFor 的统计。
+func lockLocal(name string, ch chan struct{}, timeout time.Duration, done <-chan struct{}) error {
+	select {
+	case ch <- struct{}{}:
+		return nil
+	default:
+	}
+	if timeout == 0 {
+		return ErrWaitTimeout
+	}
+
+	leave := enterWaiting(name)
+	defer leave()
+
+	var deadline <-chan time.Time
+	if timeout != waitForever {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	select {
+	case ch <- struct{}{}:
+		return nil
+	case <-done:
+		return ErrCanceled
+	case <-deadline:
+		return ErrWaitTimeout
+	}
+}
+
+// 进程内的锁本身不保证等待者获得锁的顺序，WithLocalFairness 在它之前再加一层按名字区分的公平队列。
+var fairQueues sync.Map // map[string]*fairQueue
+
+// fairQueue 是一个名字的公平队列。持有者释放时把位置直接交给排在最前面的等待者，新来的使用者无法插队。
+type fairQueue struct {
+	mu      sync.Mutex
+	held    bool
+	waiters []chan struct{}
+}
+
+// localFairQueue 返回 name 的公平队列。与 localLocks 一样，公平队列以 objectKey 区分，条目不会被删除。
+func localFairQueue(name string) *fairQueue {
+	key := objectKey(name)
+	if q, ok := fairQueues.Load(key); ok {
+		return q.(*fairQueue)
+	}
+	q, _ := fairQueues.LoadOrStore(key, new(fairQueue))
+	return q.(*fairQueue)
+}
+
+// withFair 返回先在公平队列中排到最前面、再调用 f 的 acquireFunc。排队所花的时间计入 timeout。
+// 释放时先调用 f 得到的 Releaser 的释放函数，再把位置交给下一个等待者。
+func withFair(f acquireFunc) acquireFunc {
+	return func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		q := localFairQueue(name)
+		start := time.Now()
+		if err := q.enter(name, timeout, done); err != nil {
+			return nil, err
+		}
+		if timeout != waitForever {
+			timeout -= time.Since(start)
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+
+		r, err := f(name, timeout, done)
+		if err != nil {
+			q.leave()
+			return nil, err
+		}
+		release := r.release
+		r.release = func() error {
+			err := release()
+			q.leave()
+			return err
+		}
+		return r, nil
+	}
+}
+
+// enter 在 q 中排队，直到排在最前面。需要等待时，等待期间计入 WaitersFor 的统计。
+func (q *fairQueue) enter(name string, timeout time.Duration, done <-chan struct{}) error {
+	q.mu.Lock()
+	if !q.held {
+		q.held = true
+		q.mu.Unlock()
+		return nil
+	}
+	if timeout == 0 {
+		q.mu.Unlock()
+		return ErrWaitTimeout
+	}
+	turn := make(chan struct{})
+	q.waiters = append(q.waiters, turn)
+	q.mu.Unlock()
+
+	leave := enterWaiting(name)
+	defer leave()
+
+	var deadline <-chan time.Time
+	if timeout != waitForever {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	var err error
+	select {
+	case <-turn:
+		return nil
+	case <-done:
+		err = ErrCanceled
+	case <-deadline:
+		err = ErrWaitTimeout
+	}
+
+	q.mu.Lock()
+	for i, w := range q.waiters {
+		if w == turn {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return err
+		}
+	}
+	q.mu.Unlock()
+	// 放弃等待之前已经轮到了自己，把位置交给下一个等待者。
+	q.leave()
+	return err
+}
+
+// leave 把位置交给排在最前面的等待者，没有等待者时使 q 空闲。
+func (q *fairQueue) leave() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		q.held = false
+		return
+	}
+	turn := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	close(turn)
+}
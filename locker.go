@@ -0,0 +1,54 @@
+package mutex
+
+import "sync"
+
+// NamedLocker 将名为 name 的跨进程互斥锁包装为 sync.Locker，便于传给只接受 sync.Locker 的代码。
+//
+// sync.Locker 的方法没有返回值，因此获取或释放锁失败时错误被保存起来，可以通过 LockErr 取得。
+// 获取失败时 Lock 依然会返回，但调用者并没有持有锁；需要区分这种情况的调用者应在 Lock 之后检查 LockErr，
+// 或者直接使用 Acquire。
+type NamedLocker struct {
+	name string
+
+	mu  sync.Mutex
+	r   *Releaser
+	err error
+}
+
+var _ sync.Locker = (*NamedLocker)(nil)
+
+// NewNamedLocker 创建名为 name 的 NamedLocker。创建时不会获取锁。
+func NewNamedLocker(name string) *NamedLocker {
+	return &NamedLocker{name: name}
+}
+
+// Lock 获取锁，锁被占用时一直等待。获取失败时错误被保存，可以通过 LockErr 取得，其他协程已经持有的锁不受影响。
+func (l *NamedLocker) Lock() {
+	r, err := Acquire(l.name)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.err = err
+		return
+	}
+	l.r, l.err = r, nil
+}
+
+// Unlock 释放锁。没有持有锁时什么也不做。释放失败时错误被保存，可以通过 LockErr 取得。
+func (l *NamedLocker) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.r == nil {
+		return
+	}
+	l.err = l.r.Release()
+	l.r = nil
+}
+
+// LockErr 返回最近一次 Lock 或 Unlock 的错误。
+func (l *NamedLocker) LockErr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
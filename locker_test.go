@@ -0,0 +1,64 @@
+package mutex
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNamedLocker(t *testing.T) {
+	name := testName("named_locker")
+	l := NewNamedLocker(name)
+
+	l.Lock()
+	if err := l.LockErr(); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok, _ := TryAcquire(name); ok {
+		_ = r.Release()
+		t.Fatal("expect lock held")
+	}
+	l.Unlock()
+	if err := l.LockErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := NewNamedLocker("")
+	bad.Lock()
+	if err := bad.LockErr(); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+	bad.Unlock()
+}
+
+func TestNamedLockerFailedLock(t *testing.T) {
+	name := testName("named_locker_failed_lock")
+	l := NewNamedLocker(name)
+	l.Lock()
+	if err := l.LockErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个协程的 Lock 超时失败，不能影响已经持有的锁。
+	SetDefaultTimeout(20 * time.Millisecond)
+	t.Cleanup(func() { SetDefaultTimeout(0) })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Lock()
+	}()
+	<-done
+	if err := l.LockErr(); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	l.Unlock()
+	if err := l.LockErr(); err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := TryAcquire(name)
+	if err != nil || !ok {
+		t.Fatalf("expect lock released, got %v %v", ok, err)
+	}
+	_ = r.Release()
+}
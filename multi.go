@@ -0,0 +1,125 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// AcquireAll 依次获取 names 对应的全部跨进程互斥锁。
+// 所有 names 先被排序并去重，再按顺序加锁，使所有调用者都以相同的全局顺序加锁，从而避免跨进程的死锁。
+// 任何一个锁获取失败时，已经获得的锁会被逆序释放，然后返回该错误。
+//
+// 没有使用 WaitForMultipleObjects 一次性等待所有对象：它要求所有句柄在同一个线程上等待与释放，
+// 并且无法在其他平台上实现。有序加锁已经足以避免死锁。
+// 返回 MultiReleaser 的 Release 方法用于释放全部锁资源。它必须且只能被调用一次。
+func AcquireAll(names ...string) (*MultiReleaser, error) {
+	names = sortedUnique(names)
+
+	m := &MultiReleaser{releasers: make([]*Releaser, 0, len(names))}
+	for _, name := range names {
+		r, err := Acquire(name)
+		if err != nil {
+			_ = m.Release()
+			return nil, err
+		}
+		m.releasers = append(m.releasers, r)
+	}
+	return m, nil
+}
+
+// AcquireAllContext 与 AcquireAll 相同，但整批加锁共享 ctx 的截止时间与取消。
+// 每把锁开始等待前都会检查 ctx，等待中途 ctx 被取消或超时也会立即放弃，因此每把锁实际可用的等待时间就是整批剩余的时间。
+// 放弃时已经获得的锁会被逆序释放，然后返回 ctx.Err()。
+// 返回 MultiReleaser 的 Release 方法用于释放全部锁资源。它必须且只能被调用一次。
+func AcquireAllContext(ctx context.Context, names ...string) (*MultiReleaser, error) {
+	names = sortedUnique(names)
+
+	m := &MultiReleaser{releasers: make([]*Releaser, 0, len(names))}
+	for _, name := range names {
+		r, err := AcquireContext(ctx, name)
+		if err != nil {
+			_ = m.Release()
+			return nil, err
+		}
+		m.releasers = append(m.releasers, r)
+	}
+	return m, nil
+}
+
+// AcquireFirstOf 按顺序尝试获取 names 中的锁，返回第一个成功获得的锁，适合按环境选择锁名的场景，
+// 例如先尝试 Global\ 下的名字，缺少特权时退回到 Local\ 下的名字。
+// 每个名字的等待时间为 SetDefaultTimeout 设置的默认值，可以通过 AcquireFirstOfWithOptions 指定。
+// 全部失败时返回由 errors.Join 合并的每次尝试的错误，每个错误都带有对应的锁名。names 为空时返回 ErrInvalidName。
+// 注意不同的名字是不同的锁：只有所有协作的进程都得到同一个名字时，它们之间才是互斥的。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次，Name 返回实际获得的锁名。
+func AcquireFirstOf(names ...string) (*Releaser, error) {
+	return AcquireFirstOfWithOptions(names)
+}
+
+// AcquireFirstOfWithOptions 与 AcquireFirstOf 相同，但每次尝试都按 opts 调用 AcquireWithOptions，例如通过 WithTimeout 指定每个名字的等待时间。
+func AcquireFirstOfWithOptions(names []string, opts ...Option) (*Releaser, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no names", ErrInvalidName)
+	}
+	var errs []error
+	for _, name := range names {
+		r, err := AcquireWithOptions(name, opts...)
+		if err == nil {
+			return r, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// sortedUnique 返回排序并去重后的 names 副本。
+func sortedUnique(names []string) []string {
+	s := append([]string(nil), names...)
+	sort.Strings(s)
+	j := 0
+	for i, name := range s {
+		if i > 0 && name == s[j-1] {
+			continue
+		}
+		s[j] = name
+		j++
+	}
+	return s[:j]
+}
+
+// MultiReleaser 用于释放 AcquireAll 获得的全部锁资源。
+type MultiReleaser struct {
+	releasers []*Releaser
+	released  atomic.Bool
+}
+
+// Releasers 按加锁顺序返回每个锁对应的 Releaser。它们由 MultiReleaser 负责释放，调用者不应单独释放它们。
+func (m *MultiReleaser) Releasers() []*Releaser {
+	return append([]*Releaser(nil), m.releasers...)
+}
+
+// Release 按加锁的逆序释放全部锁资源。该方法必须且只能被调用一次。
+// 某个锁释放失败时依然会继续释放其余的锁，使尽可能多的锁被释放，例如在关闭程序时。
+// 返回的错误由 errors.Join 合并了每个失败的锁的错误，每个错误都带有对应的锁名，可以使用 errors.Is 判断其中任何一个。
+// 重复调用不会再次释放锁，而是返回 ErrAlreadyReleased。
+func (m *MultiReleaser) Release() error {
+	if !m.released.CompareAndSwap(false, true) {
+		return ErrAlreadyReleased
+	}
+	var errs []error
+	for i := len(m.releasers) - 1; i >= 0; i-- {
+		r := m.releasers[i]
+		err := r.Release()
+		if errors.Is(err, ErrAlreadyReleased) {
+			// 释放过程中的错误已经带有锁名，单独释放过的锁则没有。
+			err = nameError(r.name, err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
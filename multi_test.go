@@ -0,0 +1,170 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireAll(t *testing.T) {
+	a, b := testName("acquire_all_a"), testName("acquire_all_b")
+
+	m, err := AcquireAll(b, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.Releasers()); n != 2 {
+		t.Fatalf("expect 2 releasers, got %d", n)
+	}
+
+	if r, err := AcquireWithTimeout(a, 100*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	if err := m.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+
+	for _, name := range []string{a, b} {
+		r, err := AcquireWithTimeout(name, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Release()
+	}
+}
+
+func TestAcquireAllContext(t *testing.T) {
+	a, b := testName("acquire_all_context_a"), testName("acquire_all_context_b")
+
+	rb, err := Acquire(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rb.Release() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if m, err := AcquireAllContext(ctx, b, a); !errors.Is(err, context.DeadlineExceeded) {
+		if err == nil {
+			_ = m.Release()
+		}
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+
+	// 放弃时 a 已经被释放了。
+	r, err := AcquireWithTimeout(a, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
+
+func TestAcquireAllFailure(t *testing.T) {
+	a := testName("acquire_all_failure")
+
+	// 非法的名字排在 a 之后，a 获得后才会失败。
+	if _, err := AcquireAll(a+`\x`, a); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+
+	r, err := AcquireWithTimeout(a, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
+
+func TestMultiReleaserPartialFailure(t *testing.T) {
+	a, b, c := testName("multi_partial_a"), testName("multi_partial_b"), testName("multi_partial_c")
+	m, err := AcquireAll(a, b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b 释放了锁，但报告失败。
+	injected := errors.New("injected")
+	rb := m.Releasers()[1]
+	release := rb.release
+	rb.release = func() error {
+		_ = release()
+		return injected
+	}
+	// c 在此之前已经被单独释放了。
+	if err := m.Releasers()[2].Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Release()
+	if !errors.Is(err, injected) || !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect injected and ErrAlreadyReleased, got %v", err)
+	}
+	for _, name := range []string{b, c} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("expect %q in %v", name, err)
+		}
+	}
+
+	// 其余的锁都已经被释放了。
+	for _, name := range []string{a, b, c} {
+		r, err := AcquireWithTimeout(name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Release()
+	}
+}
+
+func TestAcquireFirstOf(t *testing.T) {
+	a, b := testName("acquire_first_of_a"), testName("acquire_first_of_b")
+
+	r, err := AcquireFirstOf(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name() != a {
+		t.Fatalf("expect %q, got %q", a, r.Name())
+	}
+
+	// a 被持有时退回到 b。
+	r2, err := AcquireFirstOfWithOptions([]string{a, b}, WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.Name() != b {
+		t.Fatalf("expect %q, got %q", b, r2.Name())
+	}
+
+	// 全部失败时返回每次尝试的错误。
+	_, err = AcquireFirstOfWithOptions([]string{a, b, `bad\name`}, WithTimeout(0))
+	if !errors.Is(err, ErrWaitTimeout) || !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrWaitTimeout and ErrInvalidName, got %v", err)
+	}
+	for _, name := range []string{a, b} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("expect %q in %v", name, err)
+		}
+	}
+	_ = r.Release()
+	_ = r2.Release()
+
+	if _, err := AcquireFirstOf(); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
+
+func TestSortedUnique(t *testing.T) {
+	got := sortedUnique([]string{"c", "a", "b", "a", "c"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+}
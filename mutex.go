@@ -0,0 +1,700 @@
+// Package mutex 封装了跨进程锁。
+// windows 下基于具名互斥量实现，linux、darwin 与 BSD（freebsd、openbsd、netbsd）下基于锁文件上的 flock(2) 实现。
+// 其他平台上包依然可以被编译，但获取锁时总是返回 ErrUnsupportedPlatform。
+package mutex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var (
+	// ErrDurationTooLong 表明传入的 duration 太长。
+	//
+	// Deprecated: 等待时间已经没有上限，不会再返回这个错误。保留它只是为了兼容。
+	ErrDurationTooLong = errors.New("mutex acquire: duration too long")
+	// ErrAlreadyReleased 表明 Releaser 已经被释放过了。
+	ErrAlreadyReleased = errors.New("mutex release: already released")
+	// ErrTransferred 表明 Releaser 持有的锁已经通过 Transfer 转交给了另一个 Releaser。它包装了 ErrAlreadyReleased。
+	ErrTransferred = fmt.Errorf("%w: ownership transferred", ErrAlreadyReleased)
+	// ErrCanceled 表明等待在获得锁之前被取消了。
+	ErrCanceled = errors.New("mutex acquire: canceled")
+	// ErrStopped 表明 AcquireWithStop 的 stop 在获得锁之前被关闭了。
+	ErrStopped = errors.New("mutex acquire: stopped")
+	// ErrReleaseTimeout 表明释放锁没有在指定的时间内完成。
+	ErrReleaseTimeout = errors.New("mutex release: timeout")
+	// ErrUnsupportedPlatform 表明当前平台没有跨进程锁的实现。
+	ErrUnsupportedPlatform = errors.New("mutex acquire: unsupported platform")
+	// ErrNameTypeMismatch 表明同名的内核对象已经存在，但它不是所需的类型，例如它是其他程序创建的事件。只在 windows 下返回。
+	ErrNameTypeMismatch = errors.New("mutex acquire: name is used by an object of another type")
+	// ErrInsufficientPrivilege 表明当前进程没有创建全局命名空间（Global\）中对象所需的 SeCreateGlobalPrivilege 特权。只在 windows 下返回。
+	ErrInsufficientPrivilege = errors.New("mutex acquire: creating a global object requires SeCreateGlobalPrivilege")
+	// ErrNotOwner 表明释放的锁并不被当前使用者持有，例如 windows 下在获得互斥量的线程以外的线程上释放它。
+	// 只在 windows 下返回，返回的错误同时包装了 windows.ERROR_NOT_OWNER。
+	ErrNotOwner = errors.New("mutex release: not owner")
+	// ErrWaitTimeout 表明等待锁的时间超过了指定的最长等待时间。所有平台使用同一个值，应使用 errors.Is 或 IsTimeout 判断。
+	// windows 下操作系统的等待超时返回的错误同时包装了 windows.WAIT_TIMEOUT，只是为了兼容而保留。
+	ErrWaitTimeout = errors.New("mutex acquire: wait timeout")
+	// ErrNotExist 表明要打开的锁对象不存在。
+	ErrNotExist = errors.New("mutex acquire: not exist")
+	// ErrAbandoned 表明获得的锁被遗弃了，并因此导致了失败，例如 WithRecovery 设置的恢复函数失败。
+	// 成功获得被遗弃的锁不是错误，而是通过 Releaser.IsAbandoned 报告。
+	ErrAbandoned = errors.New("mutex acquire: abandoned")
+)
+
+// IsTimeout 表明 err 是否是因为等待锁超时而失败，即 errors.Is(err, ErrWaitTimeout)。
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrWaitTimeout)
+}
+
+// IsAbandonedErr 表明 err 是否是因为锁被遗弃而失败，即 errors.Is(err, ErrAbandoned)。
+func IsAbandonedErr(err error) bool {
+	return errors.Is(err, ErrAbandoned)
+}
+
+// 单次系统调用能够等待的最长时间。更长的等待由多次有限的等待组成。
+const max_WAIT_MILLISECONDS = time.Duration(math.MaxUint32 * time.Millisecond)
+
+// waitForever 表示无限等待。
+const waitForever time.Duration = -1
+
+// defaultTimeout 是 SetDefaultTimeout 设置的默认等待时间，单位为纳秒，0 表示无限等待。
+var defaultTimeout atomic.Int64
+
+// SetDefaultTimeout 设置不指定等待时间的获取函数的最长等待时间，d 不大于 0 时恢复默认的无限等待。
+// 设置之后，Acquire、AcquireExisting 以及没有传入 WithTimeout 的 AcquireWithOptions 等待超过 d 时返回 ErrWaitTimeout，
+// Default 返回的 Locker 的 Acquire 同样受到影响。显式指定了等待时间或取消方式的函数（AcquireWithTimeout、AcquireContext 等）不受影响。
+// 这是进程级的全局设置，会影响进程中所有使用本包的代码，包括第三方库，通常只应在程序启动时设置一次。
+func SetDefaultTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	defaultTimeout.Store(int64(d))
+}
+
+// defaultWait 返回不指定等待时间时使用的 timeout。
+func defaultWait() time.Duration {
+	if d := time.Duration(defaultTimeout.Load()); d > 0 {
+		return d
+	}
+	return waitForever
+}
+
+// Acquire 创建跨进程互斥锁。锁被占用时一直等待，除非通过 SetDefaultTimeout 设置了默认的等待时间。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func Acquire(name string) (*Releaser, error) {
+	return acquire(name, defaultWait(), nil)
+}
+
+// AcquireWithTimeout 创建跨进程互斥锁，并指定最长等待时间。
+// timeout 没有上限，超过单次系统调用的等待上限（约 49 天）时由多次等待组成，只有等满 timeout 才返回 ErrWaitTimeout。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquire(name, timeout, nil)
+}
+
+// AcquireWithTimeoutDetail 与 AcquireWithTimeout 相同，但额外返回这次获取实际等待的时间，获取失败时同样返回。
+// timeout 减去它就是剩余的等待预算，可以用于调整调用者的退避策略。它只是附加的信息，不会改变等待本身。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithTimeoutDetail(name string, timeout time.Duration) (*Releaser, time.Duration, error) {
+	start := time.Now()
+	r, err := AcquireWithTimeout(name, timeout)
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+	return r, r.acquiredAt.Sub(start), nil
+}
+
+// AcquireWithDeadline 创建跨进程互斥锁，并指定等待的截止时间。
+// 剩余的等待时间在调用时计算，截止时间已过时不会阻塞，锁被占用时直接返回 ErrWaitTimeout。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithDeadline(name string, deadline time.Time) (*Releaser, error) {
+	timeout := time.Until(deadline)
+	if timeout < 0 {
+		timeout = 0
+	}
+	return AcquireWithTimeout(name, timeout)
+}
+
+// TryAcquire 尝试创建跨进程互斥锁，如果锁已被其他使用者持有则立即返回。
+// 成功获得锁时返回的 bool 为 true，锁已被持有时为 false 且 error 为 nil。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func TryAcquire(name string) (*Releaser, bool, error) {
+	r, err := acquire(name, 0, nil)
+	if errors.Is(err, ErrWaitTimeout) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// EnsureSingleInstance 确保只有一个进程持有名为 name 的锁，常用于"同一时间只允许运行一个程序实例"。
+// 当前进程是唯一实例时返回的 bool 为 true，调用者应在退出前释放返回的 Releaser；
+// 已有其他实例持有锁时 bool 为 false 且 error 为 nil，调用者可以直接正常退出。
+func EnsureSingleInstance(name string) (*Releaser, bool, error) {
+	return TryAcquire(name)
+}
+
+// AcquireExisting 获取已经存在的跨进程互斥锁，锁对象不存在时返回 ErrNotExist，而不是创建它。
+// 适用于锁对象由另一方负责创建的场景，可以发现名字或命名空间配置错误的问题。
+// windows 下具名互斥量在最后一个句柄关闭后即被销毁，因此"存在"指的是当前有其他使用者打开着它；
+// unix 下锁文件在释放后依然保留，"存在"指的是有人使用过这个锁。
+// 与 Acquire 相同，等待时间受 SetDefaultTimeout 影响。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireExisting(name string) (*Releaser, error) {
+	return acquireWith(withLocal(osAcquireExisting), encodeName(name), defaultWait(), nil)
+}
+
+// AcquireExistingWithTimeout 获取已经存在的跨进程互斥锁，并指定最长等待时间。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireExistingWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquireWith(withLocal(osAcquireExisting), encodeName(name), timeout, nil)
+}
+
+// IsHeld 报告名为 name 的锁当前是否被任何使用者持有，它不会等待，也不会保留锁。
+// 探测的方式是尝试立即获得锁：获得时立即释放并报告锁是空闲的。
+// 结果只反映调用时的状态，返回之后锁随时可能被获得或释放，不能用它来代替加锁。
+// 探测期间锁被短暂持有，其他使用者此时的 TryAcquire 可能会失败。
+func IsHeld(name string) (bool, error) {
+	name = encodeName(name)
+	if err := validateName(name); err != nil {
+		return false, err
+	}
+	held, err := osIsHeld(name)
+	if err != nil {
+		return false, nameError(name, err)
+	}
+	return held, nil
+}
+
+// WaitUntilFree 等待名为 name 的锁变为空闲，但不持有它，等待超过 timeout 时返回 ErrWaitTimeout。
+// 它的实现是获得锁后立即释放，因此返回时只说明锁曾经空闲过，其他使用者随时可能再次获得它；
+// 需要在锁空闲期间做的事情如果要求排他，应该直接使用 Acquire。
+// 获得的锁被遗弃时遗弃状态同样会被清除，下一任持有者不会再看到 IsAbandoned 为 true。
+func WaitUntilFree(name string, timeout time.Duration) error {
+	r, err := AcquireWithTimeout(name, timeout)
+	if err != nil {
+		return err
+	}
+	return r.Release()
+}
+
+// AcquireContext 创建跨进程互斥锁，并在 ctx 被取消或超时时放弃等待。
+// 放弃等待时返回 ctx.Err()，可以使用 errors.Is 判断是 context.Canceled 还是 context.DeadlineExceeded。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireContext(ctx context.Context, name string) (*Releaser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r, err := acquire(name, waitForever, ctx.Done())
+	if errors.Is(err, ErrCanceled) {
+		return nil, ctx.Err()
+	}
+	return r, err
+}
+
+// AcquireWithStop 创建跨进程互斥锁，并在 stop 被关闭时放弃等待，返回 ErrStopped。
+// 它适合使用 stopCh 通知退出的调用者。放弃等待会真正中止底层的等待（windows 下通过唤醒 worker 线程的事件，
+// unix 下在下一次轮询时），不会留下阻塞中的线程。获得锁之后再关闭 stop 没有任何效果，调用者依然需要释放锁。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithStop(name string, stop <-chan struct{}) (*Releaser, error) {
+	select {
+	case <-stop:
+		return nil, ErrStopped
+	default:
+	}
+	r, err := acquire(name, waitForever, stop)
+	if errors.Is(err, ErrCanceled) {
+		return nil, ErrStopped
+	}
+	return r, err
+}
+
+// defaultPollSlice 是 AcquireContextPoll 的 slice 不大于 0 时使用的分片长度。
+const defaultPollSlice = 100 * time.Millisecond
+
+// AcquireContextPoll 与 AcquireContext 相同，但不把 ctx 交给底层的等待，而是以最长 slice 的分片反复等待锁，
+// 并在每两个分片之间检查 ctx。ctx 被取消或超时时返回 ctx.Err()。
+// 这以一些取消延迟换取了简单与可移植：最坏情况下 ctx 被取消后还要等待一个 slice 才会返回。
+// slice 不大于 0 时使用 100 毫秒。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireContextPoll(ctx context.Context, name string, slice time.Duration) (*Releaser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if slice <= 0 {
+		slice = defaultPollSlice
+	}
+	f := func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		for {
+			r, err := localAcquire(name, slice, nil)
+			if !errors.Is(err, ErrWaitTimeout) {
+				return r, err
+			}
+			if ctx.Err() != nil {
+				return nil, ErrCanceled
+			}
+		}
+	}
+	r, err := acquireWith(f, encodeName(name), waitForever, nil)
+	if errors.Is(err, ErrCanceled) {
+		return nil, ctx.Err()
+	}
+	return r, err
+}
+
+// AcquireResult 是 AcquireWithCancel 在后台获取锁的结果。Err 为 nil 时 Releaser 持有着锁。
+type AcquireResult struct {
+	Releaser *Releaser
+	Err      error
+}
+
+// AcquireWithCancel 开始在后台获取跨进程互斥锁并立即返回。
+// 获取的结果会被发送到 result，它只会收到一个值。cancel 放弃尚未完成的等待，此时 result 收到 ErrCanceled；
+// 获得锁之后调用 cancel 什么也不做。cancel 可以被调用多次，也可以在不同的协程中调用。
+// 收到的 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithCancel(name string) (cancel func(), result <-chan AcquireResult) {
+	done := make(chan struct{})
+	var once sync.Once
+	cancel = func() { once.Do(func() { close(done) }) }
+
+	ch := make(chan AcquireResult, 1)
+	go func() {
+		r, err := acquire(name, waitForever, done)
+		ch <- AcquireResult{Releaser: r, Err: err}
+	}()
+	return cancel, ch
+}
+
+// acquireFunc 在当前平台上获取名为 name 的锁。timeout 为 waitForever 时无限等待；
+// done 不为 nil 时，等待会在 done 被关闭后中止并返回 ErrCanceled。
+type acquireFunc func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error)
+
+// localAcquire 在进程内的锁的保护下调用当前平台的 osAcquire。
+var localAcquire = withLocal(osAcquire)
+
+// acquire 调用当前平台的 osAcquire，并对获得的 Releaser 做统一的后续处理。
+func acquire(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return acquireWith(localAcquire, encodeName(name), timeout, done)
+}
+
+// acquireWith 校验 name 后调用 f，并对获得的 Releaser 做统一的后续处理。
+func acquireWith(f acquireFunc, name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	return acquireValid(f, name, timeout, done)
+}
+
+// acquireValid 与 acquireWith 相同，但不校验 name。
+// f 可以在返回的 Releaser 中设置实际获得的锁的名字（见 AcquireAny），此时获得之后的处理与事件使用这个名字，等待期间的事件与错误依然使用 name。
+func acquireValid(f acquireFunc, name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	start := time.Now()
+	observed := observing()
+	if observed {
+		notify(name, PhaseAcquireStart, 0, nil)
+	}
+
+	r, err := f(name, timeout, done)
+	if err != nil {
+		err = nameError(name, err)
+		wait := time.Since(start)
+		recordFailure(err, wait)
+		if observed {
+			notify(name, PhaseError, wait, err)
+		}
+		return nil, err
+	}
+	if r.name != "" {
+		name = r.name
+	}
+	afterAcquire(r, name)
+	stats.waitTime.Add(int64(r.acquiredAt.Sub(start)))
+
+	if observed {
+		phase := PhaseAcquired
+		if r.isAbandoned {
+			phase = PhaseAbandoned
+		}
+		notify(name, phase, r.acquiredAt.Sub(start), nil)
+	}
+	return r, nil
+}
+
+// nameError 在 err 中加入锁的名字，使错误信息能够指出是哪个锁出了问题。
+// 返回的错误依然可以使用 errors.Is 与 errors.As 判断原本的错误。name 为空时原样返回 err。
+func nameError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("mutex %q: %w", name, err)
+}
+
+// afterAcquire 对新获得的 Releaser 做统一的后续处理。
+func afterAcquire(r *Releaser, name string) {
+	r.pin()
+	r.name = name
+	r.acquiredAt = time.Now()
+	r.tracked = true
+	register(r.lease)
+	recordAcquired(name, r.isAbandoned)
+	trackLeak(r)
+}
+
+// Locker 是跨进程锁的抽象，便于在测试中替换实现。
+type Locker interface {
+	// Acquire 创建跨进程互斥锁。
+	Acquire(name string) (*Releaser, error)
+	// AcquireWithTimeout 创建跨进程互斥锁，并指定最长等待时间。
+	AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error)
+}
+
+// Default 返回基于当前平台跨进程锁的 Locker。它的方法与同名的包级函数行为一致。
+func Default() Locker {
+	return osLocker{}
+}
+
+// osLocker 将 Locker 的方法转发给包级函数。
+type osLocker struct{}
+
+func (osLocker) Acquire(name string) (*Releaser, error) {
+	return Acquire(name)
+}
+
+func (osLocker) AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	return AcquireWithTimeout(name, timeout)
+}
+
+// Scope 返回为所有锁名加上 prefix 的 Locker，使嵌入本包的库可以拥有自己的命名空间，不与宿主程序的锁名冲突，
+// 而不需要修改每一处加锁的代码。prefix 被加在 name 开头的命名空间前缀（Global\ 等）之后，例如 Scope("lib_") 下的 Global\a 实际为 Global\lib_a。
+// 加上前缀后的锁名同样需要是合法的锁名，超过 MaxNameLength 等情况下加锁返回 ErrInvalidName，prefix 本身不能包含反斜杠。
+// 返回的 Releaser 的 Name 是加上前缀后的锁名。
+func Scope(prefix string) Locker {
+	return scopedLocker{prefix: prefix}
+}
+
+// scopedLocker 为锁名加上 prefix 后转发给包级函数。
+type scopedLocker struct {
+	prefix string
+}
+
+func (l scopedLocker) scope(name string) string {
+	rest := trimNamespace(name)
+	return name[:len(name)-len(rest)] + l.prefix + rest
+}
+
+func (l scopedLocker) Acquire(name string) (*Releaser, error) {
+	return Acquire(l.scope(name))
+}
+
+func (l scopedLocker) AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	return AcquireWithTimeout(l.scope(name), timeout)
+}
+
+// Releaser 用于释放锁资源。
+// Releaser 必须始终以 *Releaser 的形式使用，不能被复制：复制出的两个 Releaser 指向同一个锁，
+// 嵌入的 noCopy 使 go vet 报告复制，释放锁时还会检查 Releaser 是否被复制过，被复制过时 panic。
+//
+// Releaser 被释放、转交或转换（DowngradeToRead）之后不再持有锁：Release 等会影响锁的方法返回 ErrAlreadyReleased 或 ErrTransferred；
+// Name、AcquiredAt 与 WasCreated 返回释放前的值，IsAbandoned、AbandonedByPID、HeldFor 与 Handle 返回零值；IsReleased 返回 true。
+type Releaser struct {
+	noCopy      noCopy
+	addr        uintptr // Releaser 自身的地址，用于发现复制，见 checkCopy
+	isAbandoned bool
+	created     bool
+	handle      uintptr   // windows 下内核对象的句柄
+	abandonedBy int       // 遗弃锁的上一任持有者的 PID，未知时为 0
+	once        sync.Once // 见 ReleaseOnce
+	*lease
+}
+
+// noCopy 嵌入到不能被复制的结构体中，使 go vet 的 copylocks 检查报告对它的复制。
+// https://github.com/golang/go/issues/8005#issuecomment-190753527
+type noCopy struct{}
+
+func (*noCopy) Lock()   {}
+func (*noCopy) Unlock() {}
+
+// pin 记录 r 的地址，之后 checkCopy 可以发现 r 被复制了。返回 r 本身。
+// 记录的是地址的值而不是指针，不会形成自引用，因此不会妨碍泄漏检测的 finalizer。
+func (r *Releaser) pin() *Releaser {
+	r.addr = uintptr(unsafe.Pointer(r))
+	return r
+}
+
+// checkCopy 在 r 是被复制出来的 Releaser 时 panic。
+func (r *Releaser) checkCopy() {
+	if r.addr != 0 && r.addr != uintptr(unsafe.Pointer(r)) {
+		panic("mutex: Releaser copied by value, it must be used by pointer")
+	}
+}
+
+// lease 保存 Releaser 中与释放有关的状态。
+// 它与 Releaser 分开保存，使 ReleaseAll 的登记表只需引用 lease 而不是 Releaser，不会妨碍未释放的 Releaser 被垃圾回收与泄漏检测。
+// 因此 lease 以及其中的 release 不能引用 Releaser。
+type lease struct {
+	name       string
+	acquiredAt time.Time
+	tracked    bool // 是否计入了 Stats 的持有者并登记在 ReleaseAll 的登记表中
+	released   atomic.Bool
+	// transferred 表明 released 是由 Transfer 设置的。
+	transferred atomic.Bool
+	release     func() error
+	// downgrade 将写锁转换为读锁，并返回释放读锁的函数。只有读写锁的写锁不为 nil，见 DowngradeToRead。
+	downgrade func() (release func() error, err error)
+}
+
+// NewReleaser 使用 release 构造 Releaser，用于实现自定义的 Locker，例如测试替身。
+// isAbandoned 为 IsAbandoned 的返回值；release 在第一次调用 Release 时被调用，重复调用 Release 会返回 ErrAlreadyReleased。
+func NewReleaser(isAbandoned bool, release func() error) *Releaser {
+	r := &Releaser{
+		isAbandoned: isAbandoned,
+		lease:       &lease{acquiredAt: time.Now(), release: release},
+	}
+	return r.pin()
+}
+
+// Name 返回获取锁时使用的锁名，便于记录日志，释放后依然返回原来的锁名。NewReleaser 构造的 Releaser 返回空字符串。
+func (r *Releaser) Name() string {
+	return r.name
+}
+
+// IsAbandoned 表明锁的上一任持有者是否在没有释放锁时就退出了。
+// 这很可能是因为上一任持有者发生了严重错误。使用者应该检查被加锁的资源是否处于一致状态。
+// 注意此时锁已经被当前使用者所持有了，使用者依然需要调用 Release 方法。
+// 各平台检测的可靠程度不同，见 SupportsAbandoned。释放后返回 false。
+func (r *Releaser) IsAbandoned() bool {
+	return r.isAbandoned && !r.released.Load()
+}
+
+// IsReleased 表明 r 是否已经被释放、转交或转换，之后 r 不再持有锁。
+// 它只反映 r 自身的状态：通过 Transfer 等转交后锁依然被新的 Releaser 持有。
+func (r *Releaser) IsReleased() bool {
+	return r.released.Load()
+}
+
+// AcknowledgeAbandoned 表明调用者已经处理了锁被遗弃的情况（例如修复了被保护的资源），之后 IsAbandoned 返回 false，
+// AbandonedByPID 也随之返回 false，使长时间持有锁期间的诊断状态保持准确。
+// 它只修改 r 自身的状态，不会对操作系统的锁对象做任何事：遗弃状态在获得锁时就已经被清除了，下一任持有者本来就不会看到它。
+// 它不能与 r 的 IsAbandoned 等方法并发调用。
+func (r *Releaser) AcknowledgeAbandoned() {
+	r.isAbandoned = false
+}
+
+// SupportsAbandoned 表明当前平台是否实现了遗弃检测，即 IsAbandoned 是否可能返回 true。
+// 返回 false 时持有者崩溃后不会被报告，依赖 IsAbandoned 做恢复的调用者需要另行处理。各平台的保证如下：
+//   - windows：true。检测由操作系统完成（WAIT_ABANDONED），持有锁的线程退出时总会被报告。
+//   - linux、darwin 与 BSD：true，但只是近似的。检测依赖锁文件中的 PID 记录，
+//     只在所有持有者都使用本包加锁时可靠；持有者在获得 flock 后、写入 PID 之前崩溃时不会被报告。
+//   - 其他平台：false，没有跨进程锁的实现。
+func SupportsAbandoned() bool {
+	return supportsAbandoned
+}
+
+// AbandonedByPID 返回遗弃锁的上一任持有者的进程 ID。锁没有被遗弃或无法得知上一任持有者时返回的 bool 为 false。
+// 持有者在获得锁后记录自己的 PID（unix 下写入锁文件，windows 下写入伴随互斥量的共享内存），并在释放时清空，
+// 获得遗弃的锁时残留的记录就是遗弃它的进程。这是尽力而为的：只有所有持有者都使用本包加锁时记录才有意义，
+// 记录无法写入时同样返回 false。进程 ID 会被操作系统复用，它只适合用于排查问题，不应用来向该进程发送信号等。
+func (r *Releaser) AbandonedByPID() (int, bool) {
+	if !r.IsAbandoned() || r.abandonedBy <= 0 {
+		return 0, false
+	}
+	return r.abandonedBy, true
+}
+
+// WasCreated 表明锁对象是否由本次加锁创建，而不是打开了已存在的对象。
+// 它可以用来判断当前进程是否是第一个使用该锁的进程，从而进行一次性的初始化。
+// windows 下具名互斥量在最后一个句柄关闭后即被销毁，因此"第一个"指的是当前没有其他进程打开它；
+// unix 下锁文件在释放后依然保留，"第一个"指的是锁文件所在的目录被清空（通常是重启）以来的第一个。
+// 释放后依然返回原来的值。
+func (r *Releaser) WasCreated() bool {
+	return r.created
+}
+
+// AcquiredAt 返回获得锁的时间，释放后依然返回原来的值。
+func (r *Releaser) AcquiredAt() time.Time {
+	return r.acquiredAt
+}
+
+// HeldFor 返回从获得锁到现在经过的时间。释放后返回 0。
+func (r *Releaser) HeldFor() time.Duration {
+	if r.released.Load() {
+		return 0
+	}
+	return time.Since(r.acquiredAt)
+}
+
+// Release 释放锁资源。该方法必须且只能被调用一次。
+// 重复调用不会再次释放锁，而是返回 ErrAlreadyReleased。
+// Release 可以在任意协程中调用，不要求与获取锁的协程相同。windows 下真正的 ReleaseMutex 总是被交给获得锁的线程执行。
+func (r *Releaser) Release() error {
+	return r.ReleaseContext(context.Background())
+}
+
+// ReleaseContext 与 Release 相同，但 ctx 结束时释放还没有完成就不再等待，返回 ctx.Err()。
+// 它与 ReleaseWithTimeout 一样用于退出流程，使卡住的释放不会拖过退出的期限。
+// 放弃等待后释放依然在后台进行，锁不一定已经被释放；调用者不能再次释放这个 Releaser。
+func (r *Releaser) ReleaseContext(ctx context.Context) error {
+	r.checkCopy()
+	return r.releaseUntil(ctx.Done(), ctx.Err)
+}
+
+// ReleaseOnce 与 Release 相同，但可以被调用多次，适合与 defer 一起使用：
+//
+//	r, err := Acquire(name)
+//	if err != nil {
+//		return err
+//	}
+//	defer r.ReleaseOnce()
+//	...
+//	return r.ReleaseOnce() // 提前释放并检查错误，之后 defer 中的调用什么也不做
+//
+// 只有第一次调用会释放锁并返回结果，之后的调用什么也不做并返回 nil。锁已经被 Release 等其他途径释放时同样返回 nil。
+// Release 坚持"必须且只能被调用一次"的约定，重复调用会返回 ErrAlreadyReleased，便于发现逻辑错误；
+// ReleaseOnce 放弃了这种检查，只应在确实可能存在多条释放路径时使用。
+func (r *Releaser) ReleaseOnce() error {
+	var err error
+	r.once.Do(func() { err = r.Release() })
+	if errors.Is(err, ErrAlreadyReleased) {
+		return nil
+	}
+	return err
+}
+
+// ReleaseWithTimeout 与 Release 相同，但释放在 timeout 内没有完成时不再等待，返回包装了 ErrReleaseTimeout 的错误。
+// 这只应发生在负责释放的线程被卡住等极端情况下，便于在退出时记录错误后继续，而不是永远阻塞。
+// 超时后释放依然在后台进行，锁不一定已经被释放；调用者不能再次释放这个 Releaser。
+// timeout 不大于 0 时表示不限时，与 Release 相同。
+func (r *Releaser) ReleaseWithTimeout(timeout time.Duration) error {
+	r.checkCopy()
+	if timeout <= 0 {
+		return r.releaseUntil(nil, nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.releaseUntil(ctx.Done(), func() error { return nameError(r.name, ErrReleaseTimeout) })
+}
+
+// ReleaseState 与 Release 相同，但额外报告释放时是否确实持有着锁。
+// 操作系统报告当前使用者并不持有锁时（ErrNotOwner），返回 false 且 error 为 nil，
+// 这通常说明调用者的逻辑有误，例如释放了并不属于自己的锁；成功释放时返回 true；其他错误原样返回。
+// unix 下 flock 不会报告这种情况，成功释放时总是返回 true。
+func (r *Releaser) ReleaseState() (bool, error) {
+	err := r.Release()
+	if errors.Is(err, ErrNotOwner) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// releaseUntil 释放锁资源，done 被关闭时释放还没有完成就不再等待，返回 abort 的结果。done 为 nil 时一直等待释放完成。
+func (l *lease) releaseUntil(done <-chan struct{}, abort func() error) error {
+	if !l.released.CompareAndSwap(false, true) {
+		return l.releasedError()
+	}
+	if l.tracked {
+		deregister(l)
+	}
+	if observing() {
+		notify(l.name, PhaseReleaseStart, time.Since(l.acquiredAt), nil)
+	}
+	if done == nil {
+		return l.afterRelease(l.release())
+	}
+
+	ch := make(chan error, 1)
+	go func() { ch <- l.afterRelease(l.release()) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-done:
+		// 释放与 done 同时完成时以释放的结果为准。
+		select {
+		case err := <-ch:
+			return err
+		default:
+			return abort()
+		}
+	}
+}
+
+// releasedError 返回 l 已经被释放时释放它得到的错误：被转交或转换过时为 ErrTransferred，否则为 ErrAlreadyReleased。
+func (l *lease) releasedError() error {
+	if l.transferred.Load() {
+		return ErrTransferred
+	}
+	return ErrAlreadyReleased
+}
+
+// afterRelease 对释放的结果 err 做统一的后续处理。
+func (l *lease) afterRelease(err error) error {
+	if err != nil {
+		err = nameError(l.name, err)
+	}
+	if l.tracked {
+		recordReleased(l.name)
+	}
+	if observing() {
+		phase := PhaseReleased
+		if err != nil {
+			phase = PhaseError
+		}
+		notify(l.name, phase, time.Since(l.acquiredAt), err)
+	}
+	return err
+}
+
+// Close 释放锁资源，使 Releaser 满足 io.Closer 接口。
+// 它与 Release 共享"必须且只能被调用一次"的约定，对同一个 Releaser 既调用 Release 又调用 Close 是错误的用法，
+// 后调用的一方会返回 ErrAlreadyReleased。
+func (r *Releaser) Close() error {
+	return r.Release()
+}
+
+// Transfer 将释放锁的责任转交给返回的新 Releaser，锁在转交过程中一直被持有，不会出现其他使用者可以趁机获得锁的间隙。
+// 之后原 Releaser 的 Release 不再释放锁，而是返回 ErrTransferred；新 Releaser 的 Release 同样必须且只能被调用一次。
+// 新 Releaser 的其余状态与原 Releaser 相同，windows 下锁依然由获得它的线程持有与释放。
+// 对已经释放或转交过的 Releaser 调用 Transfer 时，返回的 Releaser 同样处于已释放的状态。
+func (r *Releaser) Transfer() *Releaser {
+	r.checkCopy()
+	l := &lease{
+		name:       r.name,
+		acquiredAt: r.acquiredAt,
+		tracked:    r.tracked,
+		release:    r.release,
+		downgrade:  r.downgrade,
+	}
+	t := &Releaser{
+		isAbandoned: r.isAbandoned,
+		created:     r.created,
+		handle:      r.handle,
+		abandonedBy: r.abandonedBy,
+		lease:       l,
+	}
+	t.pin()
+	if !r.released.CompareAndSwap(false, true) {
+		l.released.Store(true)
+		return t
+	}
+	r.transferred.Store(true)
+	if r.tracked {
+		deregister(r.lease)
+		register(l)
+		trackLeak(t)
+	}
+	return t
+}
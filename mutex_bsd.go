@@ -0,0 +1,9 @@
+//go:build freebsd || openbsd || netbsd
+
+package mutex
+
+// lockDir 返回存放锁文件的目录。
+// 与 darwin 一样不使用 os.TempDir()，$TMPDIR 可能是每个用户独立的，而 /tmp 在所有 BSD 上都由所有用户共享。
+func lockDir() string {
+	return "/tmp"
+}
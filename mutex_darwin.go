@@ -0,0 +1,10 @@
+package mutex
+
+// darwin 没有实现 sem_timedwait，POSIX 具名信号量也不会在持有者崩溃时自动归还，
+// 因此与 linux 一样基于锁文件上的 flock(2) 实现。
+
+// lockDir 返回存放锁文件的目录。
+// 不使用 os.TempDir()，因为 darwin 上的 $TMPDIR 是每个用户独立的，无法在不同用户的进程之间共享。
+func lockDir() string {
+	return "/tmp"
+}
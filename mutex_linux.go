@@ -0,0 +1,14 @@
+package mutex
+
+import "os"
+
+// 存放锁文件的目录。/run/lock 是大多数发行版上所有用户共享的锁目录。
+const runLockDir = "/run/lock"
+
+// lockDir 返回存放锁文件的目录。/run/lock 不存在时退回到 os.TempDir()。
+func lockDir() string {
+	if fi, err := os.Stat(runLockDir); err == nil && fi.IsDir() {
+		return runLockDir
+	}
+	return os.TempDir()
+}
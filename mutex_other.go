@@ -0,0 +1,62 @@
+//go:build !windows && !linux && !darwin && !freebsd && !openbsd && !netbsd
+
+package mutex
+
+import "time"
+
+// supportsAbandoned 见 SupportsAbandoned。
+const supportsAbandoned = false
+
+// objectKey 返回 name 本身，见 unix 下的 objectKey。
+func objectKey(name string) string {
+	return name
+}
+
+// 当前平台没有跨进程锁的实现。包依然可以被编译，所有获取锁的函数都返回 ErrUnsupportedPlatform。
+
+func osAcquire(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func osAcquireExisting(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func osIsHeld(name string) (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
+
+func osWaitersFor(name string) (int, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func osListNames(prefix string) ([]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// leaseRecord 只是为了使代码可以编译，openLeaseRecord 总是失败。
+type leaseRecord struct{}
+
+func openLeaseRecord(name string) (*leaseRecord, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (r *leaseRecord) load() (uint64, int64, error) { return 0, 0, ErrUnsupportedPlatform }
+func (r *leaseRecord) store(uint64, int64) error    { return ErrUnsupportedPlatform }
+func (r *leaseRecord) close()                       {}
+
+func osPurge(name string) error {
+	return nil
+}
+
+func enterWaiting(name string) (leave func()) {
+	return func() {}
+}
+
+// osOptions 是只在部分平台上有意义的配置。
+type osOptions struct{}
+
+// prepare 返回实际使用的锁名与获取锁的函数。当前平台不需要轮询，pollInterval 被忽略。
+func (o *osOptions) prepare(name string, pollInterval time.Duration, onAcquired func(*Releaser) error) (string, acquireFunc, error) {
+	return name, withOnAcquired(osAcquire, onAcquired), nil
+}
@@ -0,0 +1,1078 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testNonce 使每次运行测试都使用不同的锁名，上一次运行中 panic 的测试残留的锁不会影响这一次运行。
+var testNonce = fmt.Sprintf("%d_%d", os.Getpid(), time.Now().UnixNano())
+
+// testNames 记录了 testName 返回过的全部锁名，测试结束后由 TestMain 清理。
+var testNames struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// exampleNames 是示例中使用的锁名。示例的代码会出现在文档中，因此使用固定的锁名，同样由 TestMain 清理。
+var exampleNames = []string{
+	"kvii_mutex_example_acquire",
+	"kvii_mutex_example_acquire_with_timeout",
+	"kvii_mutex_example_ensure_single_instance",
+}
+
+// testName 返回测试使用的锁名，它在每次运行中都是唯一的。
+func testName(s string) string {
+	return trackName("kvii_mutex_test_" + s + "_" + testNonce)
+}
+
+// trackName 将 name 记录到 testNames 中并原样返回它，用于不是由 testName 构造的锁名。
+func trackName(name string) string {
+	testNames.mu.Lock()
+	testNames.names = append(testNames.names, name)
+	testNames.mu.Unlock()
+	return name
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) != "" {
+		os.Exit(runHelper())
+	}
+	code := m.Run()
+	testNames.mu.Lock()
+	names := append(testNames.names, exampleNames...)
+	testNames.mu.Unlock()
+	if err := Purge(names...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+func ExampleAcquire() {
+	r, err := Acquire("kvii_mutex_example_acquire")
+	if err != nil {
+		panic(err)
+	}
+	defer r.Release()
+
+	if r.IsAbandoned() {
+		// 检查被加锁的资源是否处于一致状态
+	}
+
+	// Output:
+}
+
+func ExampleAcquireWithTimeout() {
+	r, err := AcquireWithTimeout("kvii_mutex_example_acquire_with_timeout", time.Second)
+	if err != nil {
+		panic(err)
+	}
+	defer r.Release()
+
+	if r.IsAbandoned() {
+		// 检查被加锁的资源是否处于一致状态
+	}
+
+	// Output:
+}
+
+func ExampleEnsureSingleInstance() {
+	r, ok, err := EnsureSingleInstance("kvii_mutex_example_ensure_single_instance")
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		// 已有其他实例在运行
+		return
+	}
+	defer r.Release()
+
+	// Output:
+}
+
+func TestAcquire(t *testing.T) {
+	name := testName("acquire")
+	var wg sync.WaitGroup
+	var err error
+	var once sync.Once
+	var i int
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r, e := Acquire(name)
+		if e != nil {
+			once.Do(func() { err = e })
+		}
+		defer r.Release()
+
+		if r.IsAbandoned() {
+			t.Log("abandoned")
+		}
+		i++
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r, e := Acquire(name)
+		if e != nil {
+			once.Do(func() { err = e })
+		}
+		defer r.Release()
+
+		if r.IsAbandoned() {
+			t.Log("abandoned")
+		}
+		i++
+	}()
+
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 2 {
+		t.Fatalf("expect 2, got %d", i)
+	}
+}
+
+func TestAcquireWithTimeout(t *testing.T) {
+	name := testName("acquire_with_timeout")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	r2, err := AcquireWithTimeout(name, time.Second)
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), name) {
+		t.Fatalf("expect error to contain the name, got %v", err)
+	}
+}
+
+func TestAcquireContext(t *testing.T) {
+	name := testName("acquire_context")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	r2, err := AcquireContext(ctx, name)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = AcquireContext(ctx, name)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+func TestAcquireContextPoll(t *testing.T) {
+	name := testName("acquire_context_poll")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	r2, err := AcquireContextPoll(ctx, name, 20*time.Millisecond)
+	if !errors.Is(err, context.Canceled) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expect canceled within a slice, took %v", d)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { _ = r1.Release() })
+	r2, err = AcquireContextPoll(context.Background(), name, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireWithStop(t *testing.T) {
+	name := testName("acquire_with_stop")
+
+	stop := make(chan struct{})
+	close(stop)
+	if _, err := AcquireWithStop(name, stop); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expect ErrStopped, got %v", err)
+	}
+
+	// 等待中关闭 stop。
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop = make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+	if r, err := AcquireWithStop(name, stop); !errors.Is(err, ErrStopped) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrStopped, got %v", err)
+	}
+	if err := r1.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 获得锁之后关闭 stop，锁依然被持有。
+	stop = make(chan struct{})
+	r2, err := AcquireWithStop(name, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	if held, err := IsHeld(name); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if err := r2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetDefaultTimeout(t *testing.T) {
+	name := testName("set_default_timeout")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	SetDefaultTimeout(20 * time.Millisecond)
+	t.Cleanup(func() { SetDefaultTimeout(0) })
+	if r2, err := Acquire(name); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	// 恢复后 Acquire 重新无限等待。
+	SetDefaultTimeout(0)
+	time.AfterFunc(50*time.Millisecond, func() { _ = r.Release() })
+	r2, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r2.Release()
+}
+
+func TestAcquireWithTimeoutDetail(t *testing.T) {
+	name := testName("acquire_with_timeout_detail")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, waited, err := AcquireWithTimeoutDetail(name, 20*time.Millisecond); !errors.Is(err, ErrWaitTimeout) || waited < 15*time.Millisecond {
+		t.Fatalf("expect ErrWaitTimeout after 20ms, got %v after %v", err, waited)
+	}
+
+	const hold = 50 * time.Millisecond
+	time.AfterFunc(hold, func() { _ = r.Release() })
+	r2, waited, err := AcquireWithTimeoutDetail(name, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Release()
+	if waited < hold-10*time.Millisecond || waited > 2*time.Second {
+		t.Fatalf("expect about %v, got %v", hold, waited)
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	name := testName("try_acquire")
+
+	r1, ok, err := TryAcquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expect acquired")
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	r2, ok, err := TryAcquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		_ = r2.Release()
+		t.Fatal("expect not acquired")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	name := testName("default")
+	l := Default()
+
+	r1, err := l.Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	r2, err := l.AcquireWithTimeout(name, 100*time.Millisecond)
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+}
+
+func TestScope(t *testing.T) {
+	name := testName("scope")
+	const prefix = "scoped_"
+	testNames.mu.Lock()
+	testNames.names = append(testNames.names, prefix+name)
+	testNames.mu.Unlock()
+
+	// 加了前缀与没有加前缀的同名锁互不影响。
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r1.Release()
+	r2, err := Scope(prefix).AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Release()
+	if r2.Name() != prefix+name {
+		t.Fatalf("expect %q, got %q", prefix+name, r2.Name())
+	}
+	if _, err := Scope(prefix).AcquireWithTimeout(name, 0); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	// 前缀加在命名空间前缀之后。
+	if got := (scopedLocker{prefix: "p_"}).scope(`Global\a`); got != `Global\p_a` {
+		t.Fatalf("expect %q, got %q", `Global\p_a`, got)
+	}
+	if _, err := Scope(strings.Repeat("a", MaxNameLength)).Acquire("a"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
+
+func TestReleaserClose(t *testing.T) {
+	r, err := Acquire(testName("releaser_close"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c io.Closer = r
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseTwice(t *testing.T) {
+	r, err := Acquire(testName("release_twice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+}
+
+func TestReleaseOnce(t *testing.T) {
+	n := 0
+	r := NewReleaser(false, func() error {
+		n++
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		if err := r.ReleaseOnce(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("expect released once, got %d", n)
+	}
+
+	// 已经被 Release 释放的锁。
+	r, err := Acquire(testName("release_once"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReleaseOnce(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	name := testName("is_timeout")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	_, err = AcquireWithTimeout(name, 10*time.Millisecond)
+	if !IsTimeout(err) || IsAbandonedErr(err) {
+		t.Fatalf("expect timeout, got %v", err)
+	}
+	if IsTimeout(nil) || IsTimeout(ErrCanceled) || IsAbandonedErr(nil) {
+		t.Fatal("expect false")
+	}
+	if !IsAbandonedErr(fmt.Errorf("wrapped: %w", ErrAbandoned)) {
+		t.Fatal("expect abandoned")
+	}
+}
+
+func TestAcknowledgeAbandoned(t *testing.T) {
+	r := NewReleaser(true, func() error { return nil })
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned")
+	}
+	r.AcknowledgeAbandoned()
+	if r.IsAbandoned() {
+		t.Fatal("expect not abandoned after acknowledged")
+	}
+	if _, ok := r.AbandonedByPID(); ok {
+		t.Fatal("expect no pid after acknowledged")
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaserAfterRelease(t *testing.T) {
+	name := testName("releaser_after_release")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquiredAt, created := r.AcquiredAt(), r.WasCreated()
+	if r.IsReleased() {
+		t.Fatal("expect not released")
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.IsReleased() {
+		t.Fatal("expect released")
+	}
+	if r.Name() != name || !r.AcquiredAt().Equal(acquiredAt) || r.WasCreated() != created {
+		t.Fatal("expect last-known name, acquisition time and created flag")
+	}
+	if r.HeldFor() != 0 {
+		t.Fatalf("expect 0 held time, got %v", r.HeldFor())
+	}
+	for _, release := range []func() error{r.Release, r.Close, func() error { return r.ReleaseWithTimeout(time.Second) }, func() error { return r.ReleaseContext(context.Background()) }} {
+		if err := release(); !errors.Is(err, ErrAlreadyReleased) {
+			t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+		}
+	}
+	if err := r.ReleaseOnce(); err != nil {
+		t.Fatalf("expect nil from ReleaseOnce, got %v", err)
+	}
+	if held, err := r.ReleaseState(); held || !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v %v", held, err)
+	}
+	if tr := r.Transfer(); !tr.IsReleased() {
+		t.Fatal("expect transferred releaser released")
+	}
+
+	// 遗弃状态在释放后不再报告。
+	a := NewReleaser(true, func() error { return nil })
+	if err := a.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if a.IsAbandoned() {
+		t.Fatal("expect not abandoned after release")
+	}
+	if _, ok := a.AbandonedByPID(); ok {
+		t.Fatal("expect no pid after release")
+	}
+
+	// 转交后原 Releaser 同样被视为已释放。
+	b, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Transfer()
+	if !b.IsReleased() || c.IsReleased() || b.HeldFor() != 0 {
+		t.Fatal("expect only the original releaser released")
+	}
+	if err := b.Release(); !errors.Is(err, ErrTransferred) {
+		t.Fatalf("expect ErrTransferred, got %v", err)
+	}
+	if err := c.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaserCopy(t *testing.T) {
+	r, err := Acquire(testName("releaser_copy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	// 通过反射复制，go vet 不会报告这种复制，只能在运行时发现。
+	v := reflect.New(reflect.TypeOf(Releaser{}))
+	v.Elem().Set(reflect.ValueOf(r).Elem())
+	c := v.Interface().(*Releaser)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic when releasing a copied Releaser")
+		}
+	}()
+	_ = c.Release()
+}
+
+func TestReleaserNoCopy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip running go vet in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gobin, "vet", "./testdata/copylock").CombinedOutput()
+	if err == nil {
+		t.Fatal("expect go vet to fail")
+	}
+	if !strings.Contains(string(out), "copies lock") {
+		t.Fatalf("expect copylocks report, got %s", out)
+	}
+}
+
+func TestReleaseFromAnotherGoroutine(t *testing.T) {
+	name := testName("release_from_another_goroutine")
+
+	ch := make(chan *Releaser)
+	go func() {
+		r, err := Acquire(name)
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- r
+	}()
+	r := <-ch
+	if r == nil {
+		t.FailNow()
+	}
+
+	// windows 下在其他线程上调用 ReleaseMutex 会失败（ERROR_NOT_OWNER），释放成功说明它被交给了获得锁的线程。
+	errc := make(chan error)
+	go func() { errc <- r.Release() }()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
+
+func TestReleaseWithTimeout(t *testing.T) {
+	r, err := Acquire(testName("release_with_timeout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReleaseWithTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	unblock := make(chan struct{})
+	stuck := NewReleaser(false, func() error {
+		<-unblock
+		return nil
+	})
+	defer close(unblock)
+	if err := stuck.ReleaseWithTimeout(10 * time.Millisecond); !errors.Is(err, ErrReleaseTimeout) {
+		t.Fatalf("expect ErrReleaseTimeout, got %v", err)
+	}
+	if err := stuck.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+
+	// timeout 不大于 0 时等待释放完成。
+	slow := NewReleaser(false, func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	if err := slow.ReleaseWithTimeout(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseContext(t *testing.T) {
+	r, err := Acquire(testName("release_context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReleaseContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	unblock := make(chan struct{})
+	stuck := NewReleaser(false, func() error {
+		<-unblock
+		return nil
+	})
+	defer close(unblock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stuck.ReleaseContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if err := stuck.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+}
+
+func TestReleaseState(t *testing.T) {
+	r, err := Acquire(testName("release_state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := r.ReleaseState(); !held || err != nil {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if held, err := r.ReleaseState(); held || !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v %v", held, err)
+	}
+
+	notOwner := NewReleaser(false, func() error { return ErrNotOwner })
+	if held, err := notOwner.ReleaseState(); held || err != nil {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+}
+
+func TestAcquireWithDeadline(t *testing.T) {
+	name := testName("acquire_with_deadline")
+
+	r1, err := AcquireWithDeadline(name, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	start := time.Now()
+	r2, err := AcquireWithDeadline(name, start.Add(-time.Second))
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("expect no blocking, took %v", d)
+	}
+
+}
+
+func TestAcquireWithLongTimeout(t *testing.T) {
+	name := testName("acquire_with_long_timeout")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过单次系统调用上限的等待时间同样被接受，锁被释放后立即获得。
+	done := make(chan error, 1)
+	go func() {
+		r, err := AcquireWithTimeout(name, 2*max_WAIT_MILLISECONDS)
+		if err == nil {
+			err = r.Release()
+		}
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := r1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect the lock to be acquired after release")
+	}
+}
+
+func TestReleaserHeldFor(t *testing.T) {
+	before := time.Now()
+	r, err := Acquire(testName("releaser_held_for"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	if at := r.AcquiredAt(); at.Before(before) || at.After(time.Now()) {
+		t.Fatalf("unexpected AcquiredAt %v", at)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if d := r.HeldFor(); d < 10*time.Millisecond {
+		t.Fatalf("expect HeldFor >= 10ms, got %v", d)
+	}
+}
+
+func TestReleaserWasCreated(t *testing.T) {
+	// 使用唯一的名字，确保 unix 下的锁文件不是之前的测试留下的。
+	name := testName("releaser_was_created")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r1.WasCreated() {
+		t.Fatal("expect created")
+	}
+
+	// r2 在 r1 持有锁时打开锁对象，因此打开的是已存在的对象。
+	ch := make(chan *Releaser)
+	chE := make(chan error)
+	go func() {
+		r2, err := Acquire(name)
+		if err != nil {
+			chE <- err
+			return
+		}
+		ch <- r2
+	}()
+	time.Sleep(100 * time.Millisecond)
+	_ = r1.Release()
+
+	select {
+	case r2 := <-ch:
+		defer r2.Release()
+		if r2.WasCreated() {
+			t.Fatal("expect not created")
+		}
+	case err := <-chE:
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkAcquireRelease(b *testing.B) {
+	name := testName("benchmark_acquire_release")
+
+	for i := 0; i < b.N; i++ {
+		r, err := Acquire(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := r.Release(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkContended(b *testing.B) {
+	name := testName("benchmark_contended")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r, err := Acquire(name)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if err := r.Release(); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestIsHeld(t *testing.T) {
+	name := testName("is_held")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := IsHeld(name); err != nil || !held {
+		_ = r.Release()
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := IsHeld(name); err != nil || held {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+
+	// 探测不能占用锁。
+	r, err = AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
+
+func TestTransfer(t *testing.T) {
+	name := testName("transfer")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := r.Transfer()
+	if n.Name() != name || !n.AcquiredAt().Equal(r.AcquiredAt()) {
+		t.Fatalf("expect the same lock, got %q %v", n.Name(), n.AcquiredAt())
+	}
+	if err := r.Release(); !errors.Is(err, ErrTransferred) || !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrTransferred, got %v", err)
+	}
+	if held, err := IsHeld(name); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+
+	if err := n.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := IsHeld(name); err != nil || held {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+	if err := r.Transfer().Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+}
+
+func TestNoGoroutineLeak(t *testing.T) {
+	name := testName("no_goroutine_leak")
+
+	// numGoroutine 返回协程数量，数量超过 limit 时留出协程与 worker 线程退出的时间。
+	numGoroutine := func(limit int) int {
+		n := runtime.NumGoroutine()
+		for deadline := time.Now().Add(2 * time.Second); n > limit && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+			n = runtime.NumGoroutine()
+		}
+		return n
+	}
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		r, err := Acquire(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 等待超时与被取消的获取同样不能留下协程。
+		if _, err := AcquireWithTimeout(name, 0); !errors.Is(err, ErrWaitTimeout) {
+			t.Fatalf("expect ErrWaitTimeout, got %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		_, err = AcquireContext(ctx, name)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+		}
+		if err := r.Release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := numGoroutine(base); n > base {
+		t.Fatalf("expect at most %d goroutines, got %d", base, n)
+	}
+}
+
+func TestAcquireReleaseRace(t *testing.T) {
+	// 这个测试主要供 -race 使用：并发地获取与释放共享的和各自独有的锁，同时读取 Releaser 的状态、登记表与统计。
+	goroutines, iterations := 16, 50
+	if testing.Short() {
+		goroutines, iterations = 4, 10
+	}
+	shared := []string{testName("race_shared_a"), testName("race_shared_b")}
+	var holders [2]atomic.Int32
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		unique := testName(fmt.Sprintf("race_unique_%d", g))
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				k := (g + i) % len(shared)
+				r, err := AcquireWithTimeout(shared[k], 10*time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n := holders[k].Add(1); n != 1 {
+					t.Errorf("expect 1 holder of %q, got %d", shared[k], n)
+				}
+				_ = r.IsAbandoned()
+				_ = r.Name()
+				holders[k].Add(-1)
+
+				// 同一个锁被并发地释放多次，只有一次真正释放。ReleaseOnce 对重复的调用也返回 nil，
+				// 因此用 NewReleaser 构造的替身计数真正的释放次数。
+				var released atomic.Int32
+				stub := NewReleaser(false, func() error { released.Add(1); return nil })
+				var rw sync.WaitGroup
+				for j := 0; j < 3; j++ {
+					rw.Add(1)
+					go func() {
+						defer rw.Done()
+						if err := r.ReleaseOnce(); err != nil {
+							t.Error(err)
+						}
+						_ = stub.ReleaseOnce()
+					}()
+				}
+				rw.Wait()
+				if n := released.Load(); n != 1 {
+					t.Errorf("expect 1 release, got %d", n)
+				}
+				if err := r.Release(); !errors.Is(err, ErrAlreadyReleased) {
+					t.Errorf("expect ErrAlreadyReleased, got %v", err)
+				}
+
+				u, ok, err := TryAcquire(unique)
+				if err != nil || !ok {
+					t.Errorf("expect %q acquired, got %v %v", unique, ok, err)
+					return
+				}
+				u = u.Transfer()
+				_ = Stats()
+				_ = LiveLocks()
+				_ = OpenHandleCount()
+				if _, err := WaitersFor(shared[k]); err != nil {
+					t.Error(err)
+				}
+				if err := u.Release(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestWaitUntilFree(t *testing.T) {
+	name := testName("wait_until_free")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WaitUntilFree(name, 10*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = r.Release() })
+	if err := WaitUntilFree(name, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := IsHeld(name); err != nil || held {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+}
+
+func TestAcquireExisting(t *testing.T) {
+	name := testName("acquire_existing")
+
+	missing := testName("acquire_existing_missing")
+	if r, err := AcquireExisting(missing); !errors.Is(err, ErrNotExist) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrNotExist, got %v", err)
+	}
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r1.Release() })
+
+	r2, err := AcquireExistingWithTimeout(name, 100*time.Millisecond)
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+}
+
+func TestAcquireWithCancel(t *testing.T) {
+	name := testName("acquire_with_cancel")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel, result := AcquireWithCancel(name)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	cancel()
+	if res := <-result; !errors.Is(res.Err, ErrCanceled) || res.Releaser != nil {
+		t.Fatalf("expect ErrCanceled, got %v %v", res.Releaser, res.Err)
+	}
+	_ = r1.Release()
+
+	cancel, result = AcquireWithCancel(name)
+	res := <-result
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	cancel()
+	if err := res.Releaser.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,285 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// supportsAbandoned 见 SupportsAbandoned。
+const supportsAbandoned = true
+
+// 带超时或可取消的等待中两次尝试加锁之间的默认间隔。flock(2) 本身不支持超时，只能轮询。
+// 每次的实际间隔带有随机抖动，避免多个进程同步地轮询。可以通过 WithPollInterval 修改。
+const pollInterval = 50 * time.Millisecond
+
+// objectKey 返回 name 对应的锁对象在进程内的标识。unix 下每个 name 对应自己的锁文件，因此就是 name 本身。
+func objectKey(name string) string {
+	return name
+}
+
+// 大多数文件系统的文件名长度限制（NAME_MAX）。
+const maxFileNameLength = 255
+
+// companionSuffixes 是锁文件的伴生文件的名字后缀：伴生文件的名字为锁名加上后缀，
+// 分别是等待者计数、租约的锁、租约的记录与租约的锁的等待者计数。
+var companionSuffixes = []string{waitersSuffix, leaseSuffix, leaseRecordSuffix, leaseSuffix + waitersSuffix}
+
+// osPurge 删除 name 的锁文件及其所有伴生文件，见 companionSuffixes。
+func osPurge(name string) error {
+	var errs []error
+	paths := []string{lockPath(name)}
+	for _, suffix := range companionSuffixes {
+		paths = append(paths, lockPath(name+suffix))
+	}
+	for _, path := range paths {
+		if err := unix.Unlink(path); err != nil && !errors.Is(err, unix.ENOENT) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lockPath 返回 name 对应的锁文件路径。name 中文件名不允许或有歧义的字符会被转义。
+func lockPath(name string) string {
+	var b strings.Builder
+	b.WriteString("kvii_mutex_")
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	b.WriteString(".lock")
+
+	// 转义后可能超过文件名的长度限制，此时改用 name 的哈希值。
+	fileName := b.String()
+	if len(fileName) > maxFileNameLength {
+		fileName = fmt.Sprintf("kvii_mutex_%x.lock", sha256.Sum256([]byte(name)))
+	}
+	return filepath.Join(lockDir(), fileName)
+}
+
+// osAcquire 打开锁文件并在其上加 flock 排他锁。done 不为 nil 时，等待会在 done 被关闭后中止并返回 ErrCanceled。
+//
+// flock 锁在持有者进程退出时由内核自动释放。为了得知上一任持有者是否在没有释放锁时就退出了，
+// 持有者在获得锁后把自己的 PID 写入锁文件，并在 Release 时清空它。
+// 获得锁时锁文件中依然记录着 PID，说明上一任持有者没有经过 Release 就失去了锁（通常是进程崩溃），此时 IsAbandoned 返回 true。
+// 这依赖所有持有者都使用本包加锁；只用 flock 而不写入 PID 的其他程序不会被检测到。
+func osAcquire(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return pollAcquire(pollInterval)(name, timeout, done)
+}
+
+// pollAcquire 返回与 osAcquire 相同、但轮询间隔为 interval 的 acquireFunc。
+func pollAcquire(interval time.Duration) acquireFunc {
+	return func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		fd, created, err := openLockFile(lockPath(name))
+		if err != nil {
+			return nil, err
+		}
+		openHandles.Add(1)
+		return lockFile(name, fd, created, timeout, done, interval)
+	}
+}
+
+// osAcquireExisting 与 osAcquire 相同，但锁文件不存在时返回 ErrNotExist 而不是创建它。
+// 锁文件在释放后依然保留，因此只要曾经有人使用过这个锁，它就是存在的。
+func osAcquireExisting(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	fd, err := unix.Open(lockPath(name), unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	openHandles.Add(1)
+	return lockFile(name, fd, false, timeout, done, pollInterval)
+}
+
+// lockFile 在打开的锁文件 fd 上加锁并记录持有者。失败时关闭 fd。
+// fd 已经计入了 OpenHandleCount，关闭时由 closeLockFile 减去。
+// 无法立即获得锁而需要等待时，等待期间计入 WaitersFor 的统计。
+func lockFile(name string, fd int, created bool, timeout time.Duration, done <-chan struct{}, interval time.Duration) (*Releaser, error) {
+	err := flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		err = ErrWaitTimeout
+		if timeout != 0 {
+			leave := enterWaiting(name)
+			var start time.Time
+			observed := observing()
+			if observed {
+				start = time.Now()
+				notify(name, PhaseBlocked, 0, nil)
+			}
+			err = lock(fd, timeout, done, interval)
+			if observed {
+				notify(name, PhaseUnblocked, time.Since(start), nil)
+			}
+			leave()
+		}
+	}
+	if err != nil {
+		closeLockFile(fd)
+		return nil, err
+	}
+
+	stale, err := readOwner(fd)
+	if err == nil {
+		err = writeOwner(fd, os.Getpid())
+	}
+	if err != nil {
+		_ = flock(fd, unix.LOCK_UN)
+		closeLockFile(fd)
+		return nil, err
+	}
+
+	release := func() error {
+		err := writeOwner(fd, 0)
+		if e := flock(fd, unix.LOCK_UN); err == nil {
+			err = e
+		}
+		if e := closeLockFile(fd); err == nil {
+			err = e
+		}
+		return err
+	}
+	r := &Releaser{
+		isAbandoned: stale != 0,
+		created:     created,
+		lease:       &lease{release: release},
+	}
+	if stale > 0 {
+		r.abandonedBy = stale
+	}
+	return r, nil
+}
+
+// closeLockFile 关闭锁文件 fd，并将它从 OpenHandleCount 中减去。
+func closeLockFile(fd int) error {
+	openHandles.Add(-1)
+	return unix.Close(fd)
+}
+
+// osIsHeld 尝试以非阻塞方式在锁文件上加 flock 排他锁，成功时立即解锁。
+// 探测不会读写锁文件中的 PID 记录，因此不会影响下一任持有者的 IsAbandoned。
+func osIsHeld(name string) (bool, error) {
+	fd, err := unix.Open(lockPath(name), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		// 锁文件不存在，没有人使用过这个锁。
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer unix.Close(fd)
+
+	err = flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, flock(fd, unix.LOCK_UN)
+}
+
+// 锁文件中 PID 记录的最大长度。
+const maxOwnerLength = 32
+
+// readOwner 读取锁文件中记录的持有者 PID。没有记录时返回 0。
+func readOwner(fd int) (int, error) {
+	buf := make([]byte, maxOwnerLength)
+	n, err := unix.Pread(fd, buf, 0)
+	if err != nil {
+		return 0, err
+	}
+	content := strings.TrimSpace(string(buf[:n]))
+	if content == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(content)
+	if err != nil {
+		// 无法解析的内容同样说明上一任持有者没有正常清空记录。
+		return -1, nil
+	}
+	return pid, nil
+}
+
+// writeOwner 将锁文件中的持有者 PID 记录为 pid。pid 为 0 时清空记录。
+func writeOwner(fd int, pid int) error {
+	if err := unix.Ftruncate(fd, 0); err != nil {
+		return err
+	}
+	if pid == 0 {
+		return nil
+	}
+	_, err := unix.Pwrite(fd, []byte(strconv.Itoa(pid)+"\n"), 0)
+	return err
+}
+
+// openLockFile 打开锁文件，文件不存在时创建它。created 表明文件是否由本次调用创建。
+func openLockFile(path string) (fd int, created bool, err error) {
+	fd, err = unix.Open(path, unix.O_RDWR|unix.O_CREAT|unix.O_EXCL|unix.O_CLOEXEC, 0o666)
+	if err == nil {
+		return fd, true, nil
+	}
+	if !errors.Is(err, unix.EEXIST) {
+		return -1, false, err
+	}
+	fd, err = unix.Open(path, unix.O_RDWR|unix.O_CREAT|unix.O_CLOEXEC, 0o666)
+	return fd, false, err
+}
+
+// lock 在 fd 上加排他锁。无限等待且不可取消时直接阻塞在 flock 上，否则轮询。
+func lock(fd int, timeout time.Duration, done <-chan struct{}, interval time.Duration) error {
+	if timeout == waitForever && done == nil {
+		return flock(fd, unix.LOCK_EX)
+	}
+
+	var deadline <-chan time.Time
+	if timeout != waitForever {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	poll := time.NewTimer(jitter(interval))
+	defer poll.Stop()
+
+	for {
+		err := flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			return err
+		}
+
+		select {
+		case <-done:
+			return ErrCanceled
+		case <-deadline:
+			return ErrWaitTimeout
+		case <-poll.C:
+			poll.Reset(jitter(interval))
+		}
+	}
+}
+
+// flock 调用 flock(2)，并在被信号打断时重试。
+func flock(fd int, how int) error {
+	for {
+		err := unix.Flock(fd, how)
+		if !errors.Is(err, unix.EINTR) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,157 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestAcquireStaleOwner(t *testing.T) {
+	name := testName("acquire_stale_owner")
+	if !SupportsAbandoned() {
+		t.Fatal("expect abandoned detection to be supported")
+	}
+
+	// 模拟一个崩溃的持有者：锁没有被持有，但锁文件中依然记录着它的 PID。
+	if err := os.WriteFile(lockPath(name), []byte("2147483647\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned")
+	}
+	if pid, ok := r.AbandonedByPID(); !ok || pid != 2147483647 {
+		t.Fatalf("expect 2147483647, got %d %v", pid, ok)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 正常释放后记录被清空，下一任持有者不会认为锁被遗弃了。
+	r, err = Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if r.IsAbandoned() {
+		t.Fatal("expect not abandoned")
+	}
+	if _, ok := r.AbandonedByPID(); ok {
+		t.Fatal("expect no pid")
+	}
+}
+
+func TestAcquireWithRecovery(t *testing.T) {
+	name := testName("acquire_with_recovery")
+	errBroken := errors.New("broken")
+
+	if err := os.WriteFile(lockPath(name), []byte("2147483647\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	_, err := AcquireWithOptions(name, WithRecovery(func() error {
+		calls++
+		return errBroken
+	}))
+	if !errors.Is(err, errBroken) || !IsAbandonedErr(err) {
+		t.Fatalf("expect errBroken and ErrAbandoned, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expect 1 call, got %d", calls)
+	}
+
+	// 恢复失败时锁已经被释放了，否则下面的加锁会一直阻塞。
+	if err := os.WriteFile(lockPath(name), []byte("2147483647\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r, err := AcquireWithOptions(name, WithRecovery(func() error {
+		calls++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if calls != 2 {
+		t.Fatalf("expect 2 calls, got %d", calls)
+	}
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned")
+	}
+}
+
+func TestWithPollInterval(t *testing.T) {
+	name := testName("with_poll_interval")
+
+	// 直接在锁文件上加锁，模拟另一个进程持有锁，这样等待者会轮询锁文件而不是在进程内的锁上排队。
+	fd, _, err := openLockFile(lockPath(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	if err := flock(fd, unix.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AcquireWithOptions(name, WithTimeout(20*time.Millisecond), WithPollInterval(time.Millisecond)); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = flock(fd, unix.LOCK_UN) })
+	r, err := AcquireWithOptions(name, WithTimeout(5*time.Second), WithPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsTimeoutUnix(t *testing.T) {
+	if !IsTimeout(ErrWaitTimeout) || IsTimeout(unix.EWOULDBLOCK) || IsTimeout(unix.ETIMEDOUT) {
+		t.Fatal("expect only ErrWaitTimeout to be a timeout")
+	}
+}
+
+func TestParseLockFileName(t *testing.T) {
+	for _, name := range []string{"a", `Global\a b`, "%41", "a" + waitersSuffix} {
+		got, ok := parseLockFileName(filepath.Base(lockPath(name)))
+		if !ok || got != name {
+			t.Fatalf("expect %q, got %q, %v", name, got, ok)
+		}
+	}
+	for _, fileName := range []string{"a.lock", "kvii_mutex_a", "kvii_mutex_%4.lock", "kvii_mutex_%41.lock"} {
+		if name, ok := parseLockFileName(fileName); ok {
+			t.Fatalf("expect %q to be rejected, got %q", fileName, name)
+		}
+	}
+}
+
+func TestPurgeLease(t *testing.T) {
+	name := testName("purge_lease")
+	l, err := AcquireLease(name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Purge(name); err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range companionSuffixes {
+		if _, err := os.Stat(lockPath(name + suffix)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expect %s removed, got %v", suffix, err)
+		}
+	}
+}
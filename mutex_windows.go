@@ -1,110 +1,212 @@
-// Package mutex 封装了 windows 的跨进程锁。
 package mutex
 
 import (
 	"errors"
+	"fmt"
 	"runtime"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
 var (
 	// errWaitAbandoned 表明锁的上一任持有者在没有释放锁时就退出了。
-	errWaitAbandoned = errors.New("mutex acquire: wait abandoned")
-	// ErrDurationTooLong 表明传入的 duration 太长。
-	ErrDurationTooLong = errors.New("mutex acquire: duration too long")
-	// ErrWaitTimeout 表明等待锁的时间超过了指定的最长等待时间。
-	ErrWaitTimeout = windows.WAIT_TIMEOUT
+	errWaitAbandoned = fmt.Errorf("%w: wait abandoned", ErrAbandoned)
+	// errWaitTimeout 是操作系统的等待超时时返回的错误。
+	// ErrWaitTimeout 曾经就是 windows.WAIT_TIMEOUT，为了兼容依然使用 errors.Is 与它比较的代码，这里同时包装了两者。
+	errWaitTimeout = fmt.Errorf("%w: %w", ErrWaitTimeout, windows.WAIT_TIMEOUT)
 )
 
-// 最长等待时间
-const max_WAIT_MILLISECONDS = time.Duration(windows.INFINITE * time.Millisecond)
+// supportsAbandoned 见 SupportsAbandoned。
+const supportsAbandoned = true
+
+// object 描述一种可以等待并释放的具名内核对象。
+type object struct {
+	// create 创建或打开具名对象。对象已存在时返回的错误为 ERROR_ALREADY_EXISTS。
+	create func(name *uint16) (windows.Handle, error)
+	// release 释放通过等待获得的对象。
+	release func(h windows.Handle) error
+	// mutant 表明对象是互斥量。互斥量属于获得它的线程，见 ownedRecursively。
+	mutant bool
+	// onAcquired 不为 nil 时由 worker 在获得对象后立即在自己的线程上调用，返回错误时对象随即被释放，见 WithOnAcquired。
+	onAcquired func(r *Releaser) error
+}
 
-// Acquire 创建跨进程互斥锁。
-// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
-func Acquire(name string) (*Releaser, error) {
-	return acquire(name, windows.INFINITE)
+// objectKey 返回 name 对应的内核对象在进程内的标识，使指向同一个对象的不同写法得到相同的结果：
+// 命名空间前缀不区分大小写，没有前缀的名字位于 Local\ 中。没有名字的请求（FromInheritedHandle）返回空字符串。
+func objectKey(name string) string {
+	rest := trimNamespace(name)
+	prefix := name[:len(name)-len(rest)]
+	switch {
+	case name == "":
+		return ""
+	case prefix == "" || hasPrefixFold(prefix, localPrefix):
+		return localPrefix + rest
+	case hasPrefixFold(prefix, globalPrefix):
+		return globalPrefix + rest
+	default:
+		return sessionPrefix + prefix[len(sessionPrefix):] + rest
+	}
 }
 
-// AcquireWithTimeout 创建跨进程互斥锁，并指定最长等待时间。
-// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
-func AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
-	if timeout >= max_WAIT_MILLISECONDS {
-		return nil, ErrDurationTooLong
+// mutexObject 是使用默认安全描述符的具名互斥量。
+var mutexObject = newMutexObject(nil)
+
+// newMutexObject 返回使用 sa 创建的具名互斥量。sa 为 nil 时使用默认安全描述符。
+func newMutexObject(sa *windows.SecurityAttributes) object {
+	return object{
+		create: func(name *uint16) (windows.Handle, error) {
+			// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createmutexw
+			return windows.CreateMutex(sa, false, name)
+		},
+		release: releaseMutex,
+		mutant:  true,
 	}
-	return acquire(name, uint32(timeout.Milliseconds()))
 }
 
-func acquire(name string, waitMilliseconds uint32) (*Releaser, error) {
-	ch := make(chan struct{})
-	chE := make(chan error)
+// waitFailed 返回等待函数返回 WAIT_FAILED 时的错误，它包装了 GetLastError 的结果，可以使用 errors.Is 判断具体原因，
+// 例如句柄无效时为 windows.ERROR_INVALID_HANDLE。x/sys 已经在 WAIT_FAILED 时取得了 GetLastError 的结果，这里只在它缺失时补上。
+func waitFailed(err error) error {
+	if err == nil {
+		err = windows.GetLastError()
+	}
+	if err == nil {
+		// 与 x/sys 一致，errno 为 0 时使用 EINVAL。
+		err = syscall.EINVAL
+	}
+	return fmt.Errorf("mutex acquire: wait failed: %w", err)
+}
 
-	go func() {
-		// windows mutex 必须在同一个线程中操作。go 协程调度会导致线程切换，从而产生死锁。
-		runtime.LockOSThread()
+// createFailed 转换创建或打开名为 name 的对象失败的错误，返回的错误同时包装了原本的错误。
+//   - 同名的对象已经以其他类型存在时，CreateMutex 等函数返回 ERROR_INVALID_HANDLE，对应 ErrNameTypeMismatch。
+//   - 在全局命名空间中创建对象时返回 ERROR_ACCESS_DENIED，通常是缺少 SeCreateGlobalPrivilege 特权，对应 ErrInsufficientPrivilege。
+//     其他命名空间中的 ERROR_ACCESS_DENIED 来自已存在对象的安全描述符，原样返回。
+func createFailed(name string, err error) error {
+	switch {
+	case errors.Is(err, windows.ERROR_INVALID_HANDLE):
+		return fmt.Errorf("%w: %w", ErrNameTypeMismatch, err)
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED) && hasPrefixFold(name, globalPrefix):
+		return fmt.Errorf("%w: %w", ErrInsufficientPrivilege, err)
+	}
+	return err
+}
 
-		defer close(chE)
+// releaseMutex 释放互斥量 h。h 不被当前线程持有时返回的错误同时包装了 ErrNotOwner 与 windows.ERROR_NOT_OWNER。
+func releaseMutex(h windows.Handle) error {
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-releasemutex
+	err := windows.ReleaseMutex(h)
+	if errors.Is(err, windows.ERROR_NOT_OWNER) {
+		return fmt.Errorf("%w: the mutex must be released on the thread that acquired it: %w", ErrNotOwner, err)
+	}
+	return err
+}
 
-		// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createmutexw
-		mu, err := windows.CreateMutex(nil, false, windows.StringToUTF16Ptr(name))
-		if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
-			chE <- err
-			return
-		}
-		defer windows.CloseHandle(mu)
+// unexpectedWait 返回等待函数返回了未知结果 rt 时的错误。
+func unexpectedWait(rt uint32) error {
+	return fmt.Errorf("mutex acquire: unexpected wait result 0x%08x", rt)
+}
 
-		// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-waitforsingleobject
-		rt, err := windows.WaitForSingleObject(mu, waitMilliseconds)
-		if err != nil {
-			chE <- err
-			return
+// existingMutexObject 是只打开已存在的对象、不会创建新对象的具名互斥量。
+var existingMutexObject = object{
+	create: func(name *uint16) (windows.Handle, error) {
+		// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-openmutexw
+		h, err := windows.OpenMutex(windows.SYNCHRONIZE|windows.MUTEX_MODIFY_STATE, false, name)
+		if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+			return 0, ErrNotExist
 		}
-		switch rt {
-		case windows.WAIT_ABANDONED:
-			chE <- errWaitAbandoned
-			return
-		case windows.WAIT_OBJECT_0:
-			chE <- nil
-		case uint32(windows.WAIT_TIMEOUT): // unreachable if waitMilliseconds is windows.INFINITE
-			chE <- ErrWaitTimeout
-			return
-		default:
-			panic("unreachable")
+		if err != nil {
+			return 0, err
 		}
+		return h, windows.ERROR_ALREADY_EXISTS
+	},
+	release: releaseMutex,
+	mutant:  true,
+}
+
+// osAcquire 创建并等待具名互斥量。
+func osAcquire(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return acquireMutex(mutexObject, nil, name, timeout, done)
+}
 
-		<-ch
-		chE <- windows.ReleaseMutex(mu)
-	}()
+// osAcquireExisting 打开并等待已存在的具名互斥量。
+func osAcquireExisting(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return acquireMutex(existingMutexObject, nil, name, timeout, done)
+}
+
+// probeMutex 在当前协程中创建或打开 obj，并通过 NtQueryMutant 查询它是否被持有，返回它的句柄以及它是否是新创建的。
+// 互斥量被持有时关闭句柄并返回 ErrWaitTimeout。
+//
+// 零超时的加锁（例如 TryAcquire）在锁被占用时通常立即失败，为此把请求交给 worker、唤醒它的线程再等待回复的开销并不值得。
+// 查询不会获得互斥量，也不会清除遗弃状态，因此不需要锁定线程；互斥量空闲时依然交给 worker 获取，
+// 使 IsAbandoned 与其他路径一致。查询失败时视为空闲，同样交给 worker 处理。
+func probeMutex(obj object, name string) (windows.Handle, bool, error) {
+	h, err := obj.create(windows.StringToUTF16Ptr(name))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return 0, false, createFailed(name, err)
+	}
+	created := err == nil
 
-	err := <-chE
-	isAbandoned := errors.Is(err, errWaitAbandoned)
-	if err != nil && !isAbandoned {
-		close(ch)
-		return nil, err
+	var info mutantBasicInformation
+	r, _, _ := procNtQueryMutant.Call(uintptr(h), mutantBasicInformationClass, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info), 0)
+	if r == 0 && info.CurrentCount <= 0 {
+		windows.CloseHandle(h)
+		return 0, false, errWaitTimeout
 	}
+	return h, created, nil
+}
 
-	return &Releaser{
-		isAbandoned: isAbandoned,
-		release:     func() error { close(ch); return <-chE },
-	}, nil
+// ownedRecursively 表明刚刚获得的互斥量 h 在这次获得之前就已经被当前线程持有了，即这次获得只是重入。查询失败时返回 false。
+func ownedRecursively(h windows.Handle) bool {
+	var info mutantBasicInformation
+	r, _, _ := procNtQueryMutant.Call(uintptr(h), mutantBasicInformationClass, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info), 0)
+	return r == 0 && info.CurrentCount < 0
 }
 
-// Releaser 用于释放锁资源。
-type Releaser struct {
-	isAbandoned bool
-	release     func() error
+var procNtQueryMutant = modntdll.NewProc("NtQueryMutant")
+
+// mutantBasicInformationClass 是 NtQueryMutant 的 MutantBasicInformation 信息类。
+const mutantBasicInformationClass = 0
+
+// mutantBasicInformation 对应 MUTANT_BASIC_INFORMATION。CurrentCount 为 1 时互斥量空闲，不大于 0 时被持有，
+// 小于 0 时被同一个线程重入地持有了多次。
+type mutantBasicInformation struct {
+	CurrentCount   int32
+	OwnedByCaller  bool
+	AbandonedState bool
 }
 
-// IsAbandoned 表明锁的上一任持有者是否在没有释放锁时就退出了。
-// 这很可能是因为上一任持有者发生了严重错误。使用者应该检查被加锁的资源是否处于一致状态。
-// 注意此时锁已经被当前使用者所持有了，使用者依然需要调用 Release 方法。
-func (r *Releaser) IsAbandoned() bool {
-	return r.isAbandoned
+// osPurge 不需要做任何事：具名对象在所有句柄关闭后由系统销毁，不会在进程之间残留。
+func osPurge(name string) error {
+	return nil
 }
 
-// Release 释放锁资源。该方法必须且只能被调用一次。
-func (r *Releaser) Release() error {
-	return r.release()
+// osIsHeld 打开已存在的具名互斥量并以零超时等待它，获得时立即释放。
+// 获得遗弃的互斥量同样会被视为空闲，而这次探测会使下一任持有者看不到遗弃状态。
+func osIsHeld(name string) (bool, error) {
+	// 等待与释放必须在同一个线程上进行。
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-openmutexw
+	h, err := windows.OpenMutex(windows.SYNCHRONIZE|windows.MUTEX_MODIFY_STATE, false, windows.StringToUTF16Ptr(name))
+	if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+		// 互斥量不存在，没有人持有它。
+		return false, nil
+	}
+	if err != nil {
+		return false, createFailed(name, err)
+	}
+	defer windows.CloseHandle(h)
+
+	rt, err := windows.WaitForSingleObject(h, 0)
+	switch {
+	case rt == windows.WAIT_FAILED:
+		return false, waitFailed(err)
+	case rt == windows.WAIT_OBJECT_0 || rt == windows.WAIT_ABANDONED:
+		return false, releaseMutex(h)
+	default:
+		return true, nil
+	}
 }
@@ -2,99 +2,891 @@ package mutex
 
 import (
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
-func ExampleAcquire() {
-	r, err := Acquire("kvii_mutex_example_acquire")
+func TestAcquireLocal(t *testing.T) {
+	r, err := AcquireLocal(testName("acquire_local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefixNamespace(t *testing.T) {
+	n, err := prefixNamespace(globalPrefix, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != `Global\a` {
+		t.Fatalf("expect %q, got %q", `Global\a`, n)
+	}
+
+	for _, name := range []string{`Global\a`, `local\a`, `Session\1\a`} {
+		if _, err := prefixNamespace(globalPrefix, name); !errors.Is(err, ErrNamespacePrefixed) {
+			t.Fatalf("%q: expect ErrNamespacePrefixed, got %v", name, err)
+		}
+	}
+}
+
+func TestAcquireSemaphore(t *testing.T) {
+	name := testName("acquire_semaphore")
+
+	if _, err := AcquireSemaphore(name, 0); !errors.Is(err, ErrInvalidSemaphoreMax) {
+		t.Fatalf("expect ErrInvalidSemaphoreMax, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		r, err := AcquireSemaphore(name, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = r.Release() })
+	}
+
+	// 两个计数都已被占用，第三个持有者只能等待超时。
+	f := func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		return acquireObject(semaphoreObject(2), name+semaphoreSuffix, timeout, done)
+	}
+	r, err := acquireWith(f, name, 100*time.Millisecond, nil)
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+}
+
+func TestAcquireReadWrite(t *testing.T) {
+	name := testName("acquire_read_write")
+
+	r1, err := AcquireRead(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := AcquireReadWithTimeout(name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, err := AcquireWriteWithTimeout(name, 100*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = w.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	_ = r1.Release()
+	_ = r2.Release()
+
+	w, err := AcquireWriteWithTimeout(name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Release()
+
+	if r, err := AcquireReadWithTimeout(name, 100*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+}
+
+func TestDowngradeToRead(t *testing.T) {
+	name := testName("downgrade_to_read")
+
+	w, err := AcquireWrite(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 写者在转换前就开始等待，转换后依然无法获得写锁。
+	acquired := make(chan *Releaser)
+	go func() {
+		w2, err := AcquireWriteWithTimeout(name, 5*time.Second)
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
+		}
+		acquired <- w2
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	r, err := w.DowngradeToRead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Release(); !errors.Is(err, ErrTransferred) {
+		t.Fatalf("expect ErrTransferred, got %v", err)
+	}
+	if _, err := w.DowngradeToRead(); !errors.Is(err, ErrTransferred) || !strings.Contains(err.Error(), name) {
+		t.Fatalf("expect ErrTransferred naming %q, got %v", name, err)
+	}
+	if _, err := r.DowngradeToRead(); !errors.Is(err, ErrNotWriteLock) {
+		t.Fatalf("expect ErrNotWriteLock, got %v", err)
+	}
+
+	select {
+	case <-acquired:
+		t.Fatal("writer acquired the lock while it is held for reading")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	w2 := <-acquired
+	if w2 == nil {
+		return
+	}
+	if err := w2.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransferDowngrade(t *testing.T) {
+	name := testName("transfer_downgrade")
+
+	w, err := AcquireWrite(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := w.Transfer().DowngradeToRead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireWriteWithTimeout(name, 0); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	r2, err := AcquireReadWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r2.Release()
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithLocalFairnessAlias(t *testing.T) {
+	// x 与 Local\x 是同一个互斥量，在同一个公平队列中排队。
+	name := testName("with_local_fairness_alias")
+	testFairOrder(t, name, func(i int) string {
+		if i%2 == 1 {
+			return `Local\` + name
+		}
+		return name
+	})
+}
+
+func TestAcquireWithSecurity(t *testing.T) {
+	name := testName("acquire_with_security")
+
+	r, err := AcquireWithSecurity(name, "D:(A;;GA;;;WD)")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	_ = r.Release()
+
+	if _, err := AcquireWithSecurity(name, "not a sddl"); !errors.Is(err, ErrInvalidSecurityDescriptor) {
+		t.Fatalf("expect ErrInvalidSecurityDescriptor, got %v", err)
+	}
+}
+
+func TestAcquireAny(t *testing.T) {
+	a, b := testName("acquire_any_a"), testName("acquire_any_b")
+
+	ra, err := Acquire(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Release()
+
+	r, i, err := AcquireAnyWithTimeout(time.Second, a, b)
+	if err != nil {
+		t.Fatal(err)
 	}
 	defer r.Release()
+	if i != 1 {
+		t.Fatalf("expect index 1, got %d", i)
+	}
+	if r.Name() != b || r.Handle() == 0 {
+		t.Fatalf("expect name %q and a handle, got %q and %#x", b, r.Name(), r.Handle())
+	}
+	if held, _ := IsHeld(b); !held {
+		t.Fatal("expect b to be held")
+	}
+
+	if _, _, err := AcquireAnyWithTimeout(100*time.Millisecond, a, b); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if _, ok, err := TryAcquire(b); ok || err != nil {
+		t.Fatalf("expect b to be busy, got %v, %v", ok, err)
+	}
+	if _, _, err := AcquireAny(); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+	if _, _, err := AcquireAny(make([]string, maxWorkerWaits+1)...); !errors.Is(err, ErrTooManyNames) {
+		t.Fatalf("expect ErrTooManyNames, got %v", err)
+	}
+}
+
+func TestAcquireAnyAlias(t *testing.T) {
+	name := testName("acquire_any_alias")
+	r, i, err := AcquireAnyWithTimeout(time.Second, name, `Local\`+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if i != 0 {
+		t.Fatalf("expect index 0, got %d", i)
+	}
+}
+
+// BenchmarkHoldMany 同时持有 1000 个不同名字的锁。
+// 每个 worker 线程可以持有任意多个锁，因此持有的锁不会各自占用一个操作系统线程。
+func BenchmarkHoldMany(b *testing.B) {
+	const n = 1000
+	names := make([]string, n)
+	for i := range names {
+		names[i] = testName(fmt.Sprintf("benchmark_hold_many_%d", i))
+	}
+	rs := make([]*Releaser, n)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, name := range names {
+			r, err := Acquire(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			rs[j] = r
+		}
+		for _, r := range rs {
+			_ = r.Release()
+		}
+	}
+}
 
-	if r.IsAbandoned() {
-		// 检查被加锁的资源是否处于一致状态
+func TestReleaserHandle(t *testing.T) {
+	r, err := Acquire(testName("releaser_handle"))
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Output:
+	if r.Handle() == 0 {
+		_ = r.Release()
+		t.Fatal("expect non-zero handle")
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Handle() != 0 {
+		t.Fatal("expect zero handle after release")
+	}
 }
 
-func ExampleAcquireWithTimeout() {
-	r, err := AcquireWithTimeout("kvii_mutex_example_acquire_with_timeout", time.Second)
+func TestWithNamespace(t *testing.T) {
+	name := testName("with_namespace")
+
+	r, err := AcquireWithOptions(name, WithNamespace(NamespaceLocal))
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
 	defer r.Release()
+	if r2, err := AcquireWithTimeout(`Local\`+name, 0); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
 
-	if r.IsAbandoned() {
-		// 检查被加锁的资源是否处于一致状态
+	if _, err := AcquireWithOptions(`Local\`+name, WithNamespace(NamespaceGlobal)); !errors.Is(err, ErrNamespacePrefixed) {
+		t.Fatalf("expect ErrNamespacePrefixed, got %v", err)
 	}
+}
 
-	// Output:
+func TestNamespaceQualify(t *testing.T) {
+	for _, c := range []struct {
+		ns     Namespace
+		name   string
+		expect string
+	}{
+		{NamespaceDefault, "a", "a"},
+		{NamespaceDefault, `Global\a`, `Global\a`},
+		{NamespaceLocal, "a", `Local\a`},
+		{NamespaceGlobal, "a", `Global\a`},
+	} {
+		n, err := c.ns.qualify(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != c.expect {
+			t.Fatalf("expect %q, got %q", c.expect, n)
+		}
+	}
+
+	if _, err := NamespaceLocal.qualify(`Session\1\a`); !errors.Is(err, ErrNamespacePrefixed) {
+		t.Fatalf("expect ErrNamespacePrefixed, got %v", err)
+	}
+	if _, err := Namespace(-1).qualify("a"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
 }
 
-func TestAcquire(t *testing.T) {
-	const name = "kvii_mutex_test_acquire"
+func TestWaitFailed(t *testing.T) {
+	// 无效的句柄使 WaitForSingleObject 返回 WAIT_FAILED。
+	bogus := object{
+		create: func(name *uint16) (windows.Handle, error) {
+			return windows.Handle(0x7ffffff0), windows.ERROR_ALREADY_EXISTS
+		},
+		release: windows.ReleaseMutex,
+	}
+	r, err := acquireObject(bogus, testName("wait_failed"), waitForever, nil)
+	if !errors.Is(err, windows.ERROR_INVALID_HANDLE) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ERROR_INVALID_HANDLE, got %v", err)
+	}
+}
+
+func TestCancelReleasesThreads(t *testing.T) {
+	name := testName("cancel_releases_threads")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	baseline := threadCount(t)
+
+	// 同名的请求各自占用一个 worker，也就是一个线程。
+	// 这里绕过进程内的锁直接交给 worker，否则同名的请求会在进程内排队，而不是各自等待互斥量。
+	done := make(chan struct{})
 	var wg sync.WaitGroup
-	var err error
-	var once sync.Once
-	var i int
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, err := acquireObject(mutexObject, name, waitForever, done); !errors.Is(err, ErrCanceled) {
+				if err == nil {
+					_ = r.Release()
+				}
+				t.Errorf("expect ErrCanceled, got %v", err)
+			}
+		}()
+	}
+	waitUntil(t, func() bool { return pendingCount() == 100 })
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		r, e := Acquire(name)
-		if e != nil {
-			once.Do(func() { err = e })
+	close(done)
+	wg.Wait()
+
+	// 运行时可能为其他协程新建几个线程，它们不会随 worker 退出，因此允许少量的余量。
+	const slack = 4
+	waitUntil(t, func() bool {
+		pool.mu.Lock()
+		n := len(pool.workers)
+		pool.mu.Unlock()
+		return n == 1 && threadCount(t) <= baseline+slack
+	})
+}
+
+// pendingCount 返回所有 worker 上等待中的请求数。
+func pendingCount() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	n := 0
+	for _, w := range pool.workers {
+		n += w.waiting
+	}
+	return n
+}
+
+// waitUntil 等待 cond 成立，超过 5 秒时测试失败。
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
 		}
-		defer r.Release()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
 
-		if r.IsAbandoned() {
-			t.Log("abandoned")
+// threadCount 返回当前进程的线程数。
+func threadCount(t *testing.T) int {
+	t.Helper()
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	pid := windows.GetCurrentProcessId()
+	n := 0
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID == pid {
+			n++
 		}
-		i++
+	}
+	return n
+}
+
+func TestCreateFailed(t *testing.T) {
+	// 缺少 SeCreateGlobalPrivilege 的环境难以在测试中构造，这里只验证错误的转换。
+	err := createFailed(globalPrefix+"a", windows.ERROR_ACCESS_DENIED)
+	if !errors.Is(err, ErrInsufficientPrivilege) || !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		t.Fatalf("expect ErrInsufficientPrivilege and ERROR_ACCESS_DENIED, got %v", err)
+	}
+	err = createFailed("a", windows.ERROR_ACCESS_DENIED)
+	if errors.Is(err, ErrInsufficientPrivilege) || !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		t.Fatalf("expect only ERROR_ACCESS_DENIED, got %v", err)
+	}
+	err = createFailed("a", windows.ERROR_INVALID_HANDLE)
+	if !errors.Is(err, ErrNameTypeMismatch) || !errors.Is(err, windows.ERROR_INVALID_HANDLE) {
+		t.Fatalf("expect ErrNameTypeMismatch and ERROR_INVALID_HANDLE, got %v", err)
+	}
+}
+
+func TestNameTypeMismatch(t *testing.T) {
+	name := testName("name_type_mismatch")
+
+	// 同名的事件使 CreateMutex 失败。
+	ev, err := windows.CreateEvent(nil, 0, 0, windows.StringToUTF16Ptr(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(ev)
+	if r, err := Acquire(name); !errors.Is(err, ErrNameTypeMismatch) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrNameTypeMismatch, got %v", err)
+	}
+
+	// 同名的互斥锁与信号量是两个独立的对象。
+	other := name + "_mutex_and_semaphore"
+	m, err := Acquire(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Release()
+	sem, err := AcquireSemaphore(other, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sem.Release()
+}
+
+func TestAbandonedByPID(t *testing.T) {
+	name := testName("abandoned_by_pid")
+
+	// 持有记录的句柄使共享内存在模拟的持有者退出后依然存在，并写入一个虚构的 PID。
+	slot := openOwnerSlot(name, nil)
+	if slot == nil {
+		t.Fatal("expect owner slot")
+	}
+	defer slot.close()
+
+	// 模拟一个崩溃的持有者：线程获得互斥量后没有释放就退出了。
+	// 句柄保持打开，使互斥量在线程退出后依然存在。
+	var h windows.Handle
+	held := make(chan error, 1)
+	go func() {
+		// 没有调用 UnlockOSThread，协程退出时线程随之销毁，互斥量被遗弃。
+		runtime.LockOSThread()
+		var err error
+		h, err = windows.CreateMutex(nil, true, windows.StringToUTF16Ptr(name))
+		if err == nil {
+			*slot.pid = 1234
+		}
+		held <- err
 	}()
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(h)
+
+	r, err := AcquireWithTimeout(name, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, ok := r.AbandonedByPID(); !ok || pid != 1234 {
+		t.Fatalf("expect 1234, got %d %v", pid, ok)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if *slot.pid != 0 {
+		t.Fatalf("expect cleared, got %d", *slot.pid)
+	}
+}
 
-	wg.Add(1)
+func TestObjectKey(t *testing.T) {
+	for _, c := range []struct{ name, key string }{
+		{"a", `Local\a`},
+		{`Local\a`, `Local\a`},
+		{`local\a`, `Local\a`},
+		{`GLOBAL\a`, `Global\a`},
+		{`session\1\a`, `Session\1\a`},
+		{"", ""},
+	} {
+		if key := objectKey(c.name); key != c.key {
+			t.Errorf("objectKey(%q): expect %q, got %q", c.name, c.key, key)
+		}
+	}
+}
+
+func TestAcquireAlias(t *testing.T) {
+	name := testName("acquire_alias")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	// 没有前缀的名字与 Local\ 中的同名对象是同一个互斥量，同一进程内也不能同时获得它们。
+	for _, alias := range []string{`Local\` + name, `local\` + name} {
+		r2, err := AcquireWithTimeout(alias, 50*time.Millisecond)
+		if !errors.Is(err, ErrWaitTimeout) {
+			if err == nil {
+				_ = r2.Release()
+			}
+			t.Fatalf("%s: expect ErrWaitTimeout, got %v", alias, err)
+		}
+	}
+}
+
+func TestInheritHandle(t *testing.T) {
+	name := testName("inherit_handle")
+
+	r, err := AcquireWithOptions(name, WithInheritHandle(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/handleapi/nf-handleapi-gethandleinformation
+	var flags uint32
+	if ok, _, err := modkernel32.NewProc("GetHandleInformation").Call(uintptr(r.Handle()), uintptr(unsafe.Pointer(&flags))); ok == 0 {
+		t.Fatal(err)
+	}
+	if flags&windows.HANDLE_FLAG_INHERIT == 0 {
+		t.Fatal("expect inheritable handle")
+	}
+
+	// 在同一进程中复制句柄，模拟子进程继承到的句柄。
+	p := windows.CurrentProcess()
+	var child windows.Handle
+	if err := windows.DuplicateHandle(p, r.Handle(), p, &child, 0, false, windows.DUPLICATE_SAME_ACCESS); err != nil {
+		t.Fatal(err)
+	}
+	var released atomic.Bool
+	time.AfterFunc(50*time.Millisecond, func() {
+		released.Store(true)
+		_ = r.Release()
+	})
+
+	// 继承的句柄没有名字，即使被交给持有 r 的 worker，也不能重入地获得 r 持有的互斥量。
+	c, err := FromInheritedHandle(uintptr(child))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !released.Load() {
+		t.Fatal("expect acquired after the parent released")
+	}
+	if held, err := IsHeld(name); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if err := c.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireUnmanaged(t *testing.T) {
+	name := testName("acquire_unmanaged")
+
+	acquired := make(chan *UnmanagedReleaser)
+	release := make(chan struct{})
+	released := make(chan error)
 	go func() {
-		defer wg.Done()
-		r, e := Acquire(name)
-		if e != nil {
-			once.Do(func() { err = e })
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		r, err := AcquireUnmanaged(name)
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
 		}
-		defer r.Release()
+		acquired <- r
+		<-release
+		released <- r.Release()
+	}()
+
+	r := <-acquired
+	if r == nil {
+		t.FailNow()
+	}
+	if r.Name() != name || r.IsAbandoned() {
+		t.Fatalf("unexpected releaser %q %v", r.Name(), r.IsAbandoned())
+	}
+	if held, err := IsHeld(name); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+
+	close(release)
+	if err := <-released; err != nil {
+		t.Fatal(err)
+	}
+	if held, err := IsHeld(name); err != nil || held {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+}
 
-		if r.IsAbandoned() {
-			t.Log("abandoned")
+func TestReleaseNotOwner(t *testing.T) {
+	name := testName("release_not_owner")
+
+	acquired := make(chan *UnmanagedReleaser)
+	release := make(chan struct{})
+	released := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		r, err := AcquireUnmanaged(name)
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
 		}
-		i++
+		acquired <- r
+		<-release
+		released <- r.Release()
 	}()
 
-	wg.Wait()
+	r := <-acquired
+	if r == nil {
+		t.FailNow()
+	}
+	// 当前协程没有锁定在获得锁的线程上，释放必然失败。
+	if err := r.Release(); !errors.Is(err, ErrNotOwner) || !errors.Is(err, windows.ERROR_NOT_OWNER) {
+		t.Fatalf("expect ErrNotOwner, got %v", err)
+	}
+
+	close(release)
+	if err := <-released; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamedEvent(t *testing.T) {
+	name := testName("named_event")
+
+	e, err := NewEvent(name, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if i != 2 {
-		t.Fatalf("expect 2, got %d", i)
+	defer e.Close()
+	if err := e.Wait(10 * time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
 	}
-}
 
-func TestAcquireWithTimeout(t *testing.T) {
-	const name = "kvii_mutex_test_acquire_with_timeout"
+	// 同名的 NamedEvent 打开同一个事件。
+	other, err := NewEvent(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	time.AfterFunc(20*time.Millisecond, func() { _ = other.Signal() })
+	if err := e.Wait(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// 自动重置的事件唤醒一次等待后恢复为未触发状态。
+	if err := e.Wait(0); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
 
-	r1, err := Acquire(name)
+	m, err := NewEvent(name+"_manual", true)
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Cleanup(func() { _ = r1.Release() })
+	defer m.Close()
+	if err := m.Signal(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Wait(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Wait(0); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+}
 
-	r2, err := AcquireWithTimeout(name, time.Second)
-	if !errors.Is(err, ErrWaitTimeout) {
-		if err == nil {
-			_ = r2.Release()
+func TestWaitForNotify(t *testing.T) {
+	name := testName("wait_for_notify")
+
+	if err := WaitFor(name, 10*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { _ = Notify(name) })
+	if err := WaitFor(name, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 3
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() { errs <- WaitFor(name, 5*time.Second) }()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := NotifyAll(name); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
 		}
+	}
+
+	// 广播不会留给之后才开始等待的使用者。
+	if err := WaitFor(name, 10*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
 		t.Fatalf("expect ErrWaitTimeout, got %v", err)
 	}
 }
+
+func TestTryAcquireProbe(t *testing.T) {
+	name := testName("try_acquire_probe")
+
+	r, ok, err := TryAcquire(name)
+	if err != nil || !ok {
+		t.Fatalf("expect acquired, got %v %v", ok, err)
+	}
+	if !r.WasCreated() || r.IsAbandoned() {
+		t.Fatalf("expect created and not abandoned, got %v %v", r.WasCreated(), r.IsAbandoned())
+	}
+
+	// 被持有的互斥量在当前协程中就被探测为忙碌。
+	if _, err := acquireMutex(mutexObject, nil, name, 0, nil); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 探测不会清除遗弃状态。
+	abandoned := testName("try_acquire_probe_abandoned")
+	var h windows.Handle
+	held := make(chan error, 1)
+	go func() {
+		// 没有调用 UnlockOSThread，协程退出时线程随之销毁，互斥量被遗弃。
+		runtime.LockOSThread()
+		var err error
+		h, err = windows.CreateMutex(nil, true, windows.StringToUTF16Ptr(abandoned))
+		held <- err
+	}()
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(h)
+
+	// 线程是异步销毁的，在此之前互斥量依然被持有。
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, ok, err := TryAcquire(abandoned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			defer r.Release()
+			if !r.IsAbandoned() || r.WasCreated() {
+				t.Fatalf("expect abandoned and not created, got %v %v", r.IsAbandoned(), r.WasCreated())
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect the owner thread to exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func BenchmarkTryAcquireBusy(b *testing.B) {
+	name := testName("benchmark_try_acquire_busy")
+	r, err := Acquire(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Release()
+
+	// probe 是零超时时的路径，worker 是把请求交给 worker 的一般路径。进程内的锁会先于两者失败，这里绕过了它。
+	b.Run("probe", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := acquireMutex(mutexObject, nil, name, 0, nil); !errors.Is(err, ErrWaitTimeout) {
+				b.Fatalf("expect ErrWaitTimeout, got %v", err)
+			}
+		}
+	})
+	b.Run("worker", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := acquireObject(mutexObject, name, 0, nil); !errors.Is(err, ErrWaitTimeout) {
+				b.Fatalf("expect ErrWaitTimeout, got %v", err)
+			}
+		}
+	})
+}
+
+func TestIsTimeoutWindows(t *testing.T) {
+	// 操作系统的等待超时同时包装了 ErrWaitTimeout 与 windows.WAIT_TIMEOUT。
+	name := testName("is_timeout_windows")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	_, err = acquireObject(mutexObject, name, 10*time.Millisecond, nil)
+	if !IsTimeout(err) || !errors.Is(err, windows.WAIT_TIMEOUT) {
+		t.Fatalf("expect ErrWaitTimeout and WAIT_TIMEOUT, got %v", err)
+	}
+	if IsTimeout(windows.ERROR_TIMEOUT) {
+		t.Fatal("expect ERROR_TIMEOUT not to be ErrWaitTimeout")
+	}
+	if !IsAbandonedErr(errWaitAbandoned) {
+		t.Fatal("expect errWaitAbandoned to be ErrAbandoned")
+	}
+}
+
+func TestWithOnAcquiredHandle(t *testing.T) {
+	var h windows.Handle
+	r, err := AcquireWithOptions(testName("with_on_acquired_handle"), WithOnAcquired(func(r *Releaser) error {
+		h = r.Handle()
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if h == 0 || h != r.Handle() {
+		t.Fatalf("expect handle %v, got %v", r.Handle(), h)
+	}
+}
@@ -0,0 +1,109 @@
+// Package mutextest 提供了 mutex.Locker 的进程内实现，用于在单元测试中代替真正的跨进程锁，
+// 以及清理真实的跨进程锁的辅助函数。
+package mutextest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kvii/mutex"
+)
+
+// Locker 是基于进程内锁的 mutex.Locker 实现，不会调用任何系统调用。
+// 同一个 Locker 上同名的锁相互排斥，不同 Locker 之间互不影响，因此并行的测试不会相互干扰。
+// 零值不可用，请使用 New 创建。
+type Locker struct {
+	mu        sync.Mutex
+	locks     map[string]chan struct{}
+	abandoned map[string]bool
+	timeouts  map[string]int
+}
+
+var _ mutex.Locker = (*Locker)(nil)
+
+// New 创建 Locker。
+func New() *Locker {
+	return &Locker{
+		locks:     make(map[string]chan struct{}),
+		abandoned: make(map[string]bool),
+		timeouts:  make(map[string]int),
+	}
+}
+
+// Acquire 获取名为 name 的锁，锁被占用时一直等待。
+func (l *Locker) Acquire(name string) (*mutex.Releaser, error) {
+	return l.acquire(name, nil)
+}
+
+// AcquireWithTimeout 获取名为 name 的锁，等待超过 timeout 时返回 mutex.ErrWaitTimeout。
+func (l *Locker) AcquireWithTimeout(name string, timeout time.Duration) (*mutex.Releaser, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	return l.acquire(name, t.C)
+}
+
+// Abandon 模拟上一任持有者在没有释放锁时就退出了：下一次成功获取名为 name 的锁时，IsAbandoned 返回 true。
+func (l *Locker) Abandon(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.abandoned[name] = true
+}
+
+// Timeout 模拟等待超时：接下来 n 次获取名为 name 的锁时，无论锁是否空闲都立即返回 mutex.ErrWaitTimeout。
+func (l *Locker) Timeout(name string, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timeouts[name] += n
+}
+
+// IsHeld 表明名为 name 的锁当前是否被持有。
+func (l *Locker) IsHeld(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.locks[name]) > 0
+}
+
+func (l *Locker) acquire(name string, timeout <-chan time.Time) (*mutex.Releaser, error) {
+	l.mu.Lock()
+	if l.timeouts[name] > 0 {
+		l.timeouts[name]--
+		l.mu.Unlock()
+		return nil, mutex.ErrWaitTimeout
+	}
+	ch, ok := l.locks[name]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		l.locks[name] = ch
+	}
+	l.mu.Unlock()
+
+	// 先尝试不等待地加锁，避免零超时的计时器与空闲的锁同时就绪时随机选中超时。
+	select {
+	case ch <- struct{}{}:
+	default:
+		select {
+		case ch <- struct{}{}:
+		case <-timeout:
+			return nil, mutex.ErrWaitTimeout
+		}
+	}
+
+	l.mu.Lock()
+	abandoned := l.abandoned[name]
+	delete(l.abandoned, name)
+	l.mu.Unlock()
+
+	return mutex.NewReleaser(abandoned, func() error {
+		<-ch
+		return nil
+	}), nil
+}
+
+// Cleanup 释放当前进程持有的名为 names 的真实跨进程锁，并清除它们留在系统中的状态，
+// 避免 panic 的测试残留的锁使之后的运行意外地看到遗弃状态。它适合在 TestMain 中 m.Run 之前或之后调用，详见 mutex.Purge。
+func Cleanup(names ...string) error {
+	return mutex.Purge(names...)
+}
@@ -0,0 +1,95 @@
+package mutextest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kvii/mutex"
+)
+
+func TestLocker(t *testing.T) {
+	const name = "a"
+	l := New()
+
+	r1, err := l.Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsHeld(name) {
+		t.Fatal("expect held")
+	}
+
+	if _, err := l.AcquireWithTimeout(name, 10*time.Millisecond); !errors.Is(err, mutex.ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	if err := r1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if l.IsHeld(name) {
+		t.Fatal("expect not held")
+	}
+
+	r2, err := New().AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r2.Release()
+}
+
+func TestLockerAbandon(t *testing.T) {
+	l := New()
+	l.Abandon("a")
+
+	r, err := l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned")
+	}
+	_ = r.Release()
+
+	r, err = l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.IsAbandoned() {
+		t.Fatal("expect not abandoned")
+	}
+	_ = r.Release()
+}
+
+func TestLockerTimeout(t *testing.T) {
+	l := New()
+	l.Timeout("a", 1)
+
+	if _, err := l.Acquire("a"); !errors.Is(err, mutex.ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	r, err := l.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Release()
+}
+
+func TestCleanup(t *testing.T) {
+	name := fmt.Sprintf("kvii_mutex_test_mutextest_cleanup_%d", time.Now().UnixNano())
+
+	r, err := mutex.Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Cleanup(name); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := mutex.IsHeld(name); err != nil || held {
+		t.Fatalf("expect not held, got %v %v", held, err)
+	}
+	if err := r.Release(); !errors.Is(err, mutex.ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package mutex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrInvalidName 表明 name 不是合法的锁名。返回的错误包装了它，并在错误信息中说明了具体原因。
+var ErrInvalidName = errors.New("mutex acquire: invalid name")
+
+// MaxNameLength 是锁名的最大长度（MAX_PATH），以 UTF-16 编码单元计，包含命名空间前缀。
+const MaxNameLength = 260
+
+// windows 内核对象命名空间前缀。
+// https://learn.microsoft.com/zh-cn/windows/win32/termserv/kernel-object-namespaces
+const (
+	globalPrefix  = `Global\`
+	localPrefix   = `Local\`
+	sessionPrefix = `Session\`
+)
+
+// NormalizeName 将任意字符串转换为稳定的合法锁名。
+// 合法的 name 原样返回；否则（例如超过 MaxNameLength）保留 name 开头的命名空间前缀与最多 64 个字符的可读前缀，
+// 并在其后拼接 name 的 SHA-256 哈希值的前 32 个十六进制字符。同一个 name 总是得到同一个结果，因此跨进程的互斥依然成立。
+//
+// 哈希值截断为 128 位，n 个不同的名字发生碰撞的概率约为 n²/2¹²⁹，实际使用中可以忽略。
+// 需要规范化的调用者在加锁时显式调用它即可，例如 Acquire(NormalizeName(path))。
+// 所有协作的进程都必须使用相同的方式得到锁名。
+func NormalizeName(name string) string {
+	if validateName(name) == nil {
+		return name
+	}
+
+	rest := trimNamespace(name)
+	prefix := name[:len(name)-len(rest)]
+	if utf16Len(prefix) > MaxNameLength-normalizedSuffixLength-2*normalizedReadableLength {
+		// 只有会话 ID 长得离谱的 Session\<id>\ 前缀才会这么长，它不是有效的命名空间，整体作为普通字符处理。
+		prefix, rest = "", name
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	n := 0
+	for _, r := range rest {
+		if n == normalizedReadableLength {
+			break
+		}
+		if r == 0 || r == '\\' {
+			r = '_'
+		}
+		b.WriteRune(r)
+		n++
+	}
+	sum := sha256.Sum256([]byte(name))
+	b.WriteByte('~')
+	b.WriteString(hex.EncodeToString(sum[:normalizedHashBytes]))
+	return b.String()
+}
+
+var nameEncoder atomic.Pointer[func(string) string]
+
+// SetNameEncoder 设置锁名的编码器，传入 nil 时恢复默认的原样使用。
+// 设置之后，所有接受锁名的函数都先用 encode 转换 name，再将结果作为实际的锁名使用，
+// 例如转换为 SHA-256 哈希值、可逆的编码，或是加上统一的前缀。
+// encode 只作用于命名空间前缀（Global\ 等）之后的部分，前缀原样保留；转换的结果依然需要是合法的锁名。
+// Releaser.Name、Event.Name、Stats 与错误信息中的锁名都是转换后的结果。
+//
+// 所有协作的进程都必须使用相同的编码器，否则同一个 name 对应不同的锁，相互之间不再互斥。
+// encode 可能被并发调用，它应该是纯函数。应在获取任何锁之前设置编码器，
+// 持有锁期间更换编码器会使 Purge 等按锁名查找的函数找不到之前获得的锁。
+func SetNameEncoder(encode func(raw string) string) {
+	if encode == nil {
+		nameEncoder.Store(nil)
+		return
+	}
+	nameEncoder.Store(&encode)
+}
+
+// encodeName 使用 SetNameEncoder 设置的编码器转换 name。
+func encodeName(name string) string {
+	f := nameEncoder.Load()
+	if f == nil {
+		return name
+	}
+	rest := trimNamespace(name)
+	return name[:len(name)-len(rest)] + (*f)(rest)
+}
+
+// NormalizeName 保留的可读前缀的最大字符数与哈希值的字节数。
+const (
+	normalizedReadableLength = 64
+	normalizedHashBytes      = 16
+
+	// normalizedSuffixLength 是 "~" 与十六进制哈希值的长度。
+	normalizedSuffixLength = 1 + 2*normalizedHashBytes
+)
+
+// validateName 检查 name 是否为合法的锁名：
+// 不能为空，长度不能超过 MaxNameLength，不能包含 NUL，
+// 除了开头的命名空间前缀（Global\、Local\ 或 Session\<id>\）外不能包含反斜杠。
+// 所有平台使用相同的规则，以便同一个 name 在各平台上都可用。
+func validateName(name string) error {
+	if name == "" {
+		return invalidName(name, "empty")
+	}
+	if n := utf16Len(name); n > MaxNameLength {
+		return invalidName(name, fmt.Sprintf("length %d exceeds %d", n, MaxNameLength))
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return invalidName(name, "contains NUL")
+	}
+
+	rest := trimNamespace(name)
+	if rest == "" {
+		return invalidName(name, "empty after namespace prefix")
+	}
+	if strings.IndexByte(rest, '\\') >= 0 {
+		return invalidName(name, `contains '\' outside of the namespace prefix`)
+	}
+	return nil
+}
+
+func invalidName(name, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidName, name, reason)
+}
+
+// trimNamespace 去掉 name 开头的命名空间前缀。命名空间前缀不区分大小写。
+func trimNamespace(name string) string {
+	for _, p := range []string{globalPrefix, localPrefix} {
+		if hasPrefixFold(name, p) {
+			return name[len(p):]
+		}
+	}
+	if hasPrefixFold(name, sessionPrefix) {
+		rest := name[len(sessionPrefix):]
+		i := strings.IndexByte(rest, '\\')
+		if i > 0 && strings.Trim(rest[:i], "0123456789") == "" {
+			return rest[i+1:]
+		}
+	}
+	return name
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// utf16Len 返回 s 编码为 UTF-16 后的长度。
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
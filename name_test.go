@@ -0,0 +1,207 @@
+package mutex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	valid := []string{
+		"a",
+		`Global\a`,
+		`local\a`,
+		`Session\1\a`,
+		strings.Repeat("a", MaxNameLength),
+		strings.Repeat("中", MaxNameLength),
+	}
+	for _, name := range valid {
+		if err := validateName(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		`Global\`,
+		strings.Repeat("a", MaxNameLength+1),
+		strings.Repeat("😀", MaxNameLength/2+1),
+		"a\x00b",
+		`a\b`,
+		`Global\a\b`,
+		`Session\x\a`,
+	}
+	for _, name := range invalid {
+		if err := validateName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%q: expect ErrInvalidName, got %v", name, err)
+		}
+	}
+}
+
+func TestAcquireInvalidName(t *testing.T) {
+	if _, err := Acquire(""); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
+
+func TestAcquireLongName(t *testing.T) {
+	prefix := testName("long_name")
+	r, err := Acquire(trackName(prefix + strings.Repeat("中", MaxNameLength-len(prefix))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	if n := NormalizeName("a"); n != "a" {
+		t.Fatalf("expect valid name unchanged, got %q", n)
+	}
+
+	long := `Global\` + strings.Repeat("a", MaxNameLength)
+	n := NormalizeName(long)
+	if err := validateName(n); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(n, `Global\aaaa`) {
+		t.Fatalf("expect namespace and readable prefix kept, got %q", n)
+	}
+	if n2 := NormalizeName(long); n2 != n {
+		t.Fatalf("expect stable result, got %q and %q", n, n2)
+	}
+	if n2 := NormalizeName(long + "b"); n2 == n {
+		t.Fatalf("expect different names to differ, got %q", n2)
+	}
+
+	for _, name := range []string{"", `a\b`, "a\x00b"} {
+		if err := validateName(NormalizeName(name)); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+}
+
+func TestSetNameEncoder(t *testing.T) {
+	prefix := testName("name_encoder") + "_"
+	encode := func(raw string) string {
+		sum := sha256.Sum256([]byte(raw))
+		return prefix + hex.EncodeToString(sum[:8])
+	}
+	SetNameEncoder(encode)
+	t.Cleanup(func() { SetNameEncoder(nil) })
+
+	// 编码器使原本不合法的 name 也可以使用。
+	raw := `C:\data\app.db`
+	r, err := Acquire(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name() != encode(raw) {
+		t.Fatalf("expect %q, got %q", encode(raw), r.Name())
+	}
+	if held, err := IsHeld(raw); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if _, ok, _ := TryAcquire(raw); ok {
+		t.Fatal("expect not acquired")
+	}
+
+	// 命名空间前缀原样保留。
+	if got, want := encodeName(`Global\`+raw), `Global\`+encode(raw); got != want {
+		t.Fatalf("expect %q, got %q", want, got)
+	}
+
+	SetNameEncoder(nil)
+	if held, err := IsHeld(encode(raw)); err != nil || !held {
+		t.Fatalf("expect held, got %v %v", held, err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Purge(encode(raw)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzAcquireName(f *testing.F) {
+	for _, s := range []string{
+		"",
+		"a",
+		"a\x00b",
+		`a\b`,
+		`Global\`,
+		`global\a`,
+		`Session\`,
+		`Session\1\`,
+		`Session\1\a\b`,
+		`Session\` + strings.Repeat("1", MaxNameLength) + `\a`,
+		"\xed\xa0\x80", // 单独的代理项（surrogate half）
+		"\xff\xfe",
+		strings.Repeat("𝄞", MaxNameLength),
+		strings.Repeat(`\`, MaxNameLength+1),
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		valid := validateName(name) == nil
+		n := NormalizeName(name)
+		if err := validateName(n); err != nil {
+			t.Fatalf("NormalizeName(%q) = %q is invalid: %v", name, n, err)
+		}
+		if valid && n != name {
+			t.Fatalf("NormalizeName(%q) changed a valid name to %q", name, n)
+		}
+		if NormalizeName(n) != n {
+			t.Fatalf("NormalizeName is not idempotent on %q", n)
+		}
+	})
+}
+
+func TestBuildName(t *testing.T) {
+	cases := []struct {
+		ns     Namespace
+		parts  []string
+		expect string
+	}{
+		{NamespaceDefault, []string{"app", "job"}, "app.job"},
+		{NamespaceLocal, []string{"app"}, `Local\app`},
+		{NamespaceGlobal, []string{"app", "a.b", "50%"}, `Global\app.a%2Eb.50%25`},
+	}
+	for _, c := range cases {
+		if got, err := BuildName(c.ns, c.parts...); err != nil || got != c.expect {
+			t.Fatalf("BuildName(%d, %q): expect %q, got %q %v", c.ns, c.parts, c.expect, got, err)
+		}
+	}
+	// 转义使不同的 parts 得到不同的锁名。
+	if MustBuildName(NamespaceDefault, "a.b") == MustBuildName(NamespaceDefault, "a", "b") {
+		t.Fatal("expect different names")
+	}
+
+	bad := []struct {
+		ns    Namespace
+		parts []string
+	}{
+		{NamespaceDefault, nil},
+		{NamespaceDefault, []string{"a", ""}},
+		{NamespaceDefault, []string{"a\x00"}},
+		{NamespaceDefault, []string{`a\b`}},
+		{NamespaceGlobal, []string{`Global\a`}},
+		{NamespaceDefault, []string{strings.Repeat("a", MaxNameLength+1)}},
+		{Namespace(-1), []string{"a"}},
+	}
+	for _, c := range bad {
+		if _, err := BuildName(c.ns, c.parts...); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("BuildName(%d, %q): expect ErrInvalidName, got %v", c.ns, c.parts, err)
+		}
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("MustBuildName: expect ErrInvalidName, got %v", err)
+		}
+	}()
+	MustBuildName(NamespaceDefault)
+}
@@ -0,0 +1,97 @@
+package mutex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNamespacePrefixed 表明传给 AcquireGlobal 或 AcquireLocal 的 name 已经带有命名空间前缀。
+var ErrNamespacePrefixed = errors.New("mutex acquire: name already has a namespace prefix")
+
+// Namespace 是具名内核对象所在的命名空间。
+// 终端服务器（多个远程桌面会话）上，每个会话有自己的命名空间，会话之间需要通过全局命名空间共享对象。
+// 命名空间只在 windows 下有意义，其他平台上命名空间前缀只是锁名的一部分。
+type Namespace int
+
+const (
+	// NamespaceDefault 不添加前缀，name 原样传给 CreateMutex。不带前缀的名字位于当前会话的命名空间，name 也可以自带前缀。
+	NamespaceDefault Namespace = iota
+	// NamespaceLocal 添加 Local\ 前缀，锁只在同一会话的进程之间共享，与不带前缀的名字相同。不需要任何特权。
+	NamespaceLocal
+	// NamespaceGlobal 添加 Global\ 前缀，锁在所有会话之间共享，包括会话 0 中的服务。
+	// 在会话 0 以外的会话中创建全局对象需要 SeCreateGlobalPrivilege 特权，打开已经存在的全局对象则不需要。
+	NamespaceGlobal
+)
+
+// qualify 返回 name 在 ns 中的完整名字。
+func (ns Namespace) qualify(name string) (string, error) {
+	switch ns {
+	case NamespaceDefault:
+		return name, nil
+	case NamespaceLocal:
+		return prefixNamespace(localPrefix, name)
+	case NamespaceGlobal:
+		return prefixNamespace(globalPrefix, name)
+	}
+	return "", fmt.Errorf("%w: unknown namespace %d", ErrInvalidName, int(ns))
+}
+
+// prefixNamespace 为 name 加上命名空间前缀。
+func prefixNamespace(prefix, name string) (string, error) {
+	for _, p := range []string{globalPrefix, localPrefix, sessionPrefix} {
+		if hasPrefixFold(name, p) {
+			return "", ErrNamespacePrefixed
+		}
+	}
+	return prefix + name, nil
+}
+
+// nameSeparator 是 BuildName 连接各个部分时使用的分隔符。
+const nameSeparator = "."
+
+// nameEscaper 转义 BuildName 的各个部分中的分隔符与转义字符本身，使不同的 parts 不会得到相同的锁名。
+var nameEscaper = strings.NewReplacer("%", "%25", nameSeparator, "%2E")
+
+// BuildName 在 namespace 中以 parts 构造锁名，避免手工拼接锁名时写错分隔符或命名空间前缀，
+// 使两个进程在没有任何错误的情况下用上了不同的对象，互斥悄悄地失效。
+// 各个部分依次以 "." 连接，部分中的 "." 与 "%" 被转义为 "%2E" 与 "%25"，因此不同的 parts 总是得到不同的锁名，
+// 例如 BuildName(NamespaceGlobal, "app", "a.b") 得到 Global\app.a%2Eb。返回的字符串就是实际使用的锁名（SetNameEncoder 之前），可以直接用于日志。
+//
+// parts 不能为空，其中的部分不能为空字符串，也不能包含 NUL 与反斜杠；namespace 必须是已知的命名空间，结果不能超过 MaxNameLength。
+// 违反时返回包装了 ErrInvalidName 的错误。
+func BuildName(namespace Namespace, parts ...string) (string, error) {
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%w: no parts", ErrInvalidName)
+	}
+	escaped := make([]string, len(parts))
+	for i, p := range parts {
+		switch {
+		case p == "":
+			return "", fmt.Errorf("%w: part %d is empty", ErrInvalidName, i)
+		case strings.IndexByte(p, 0) >= 0:
+			return "", fmt.Errorf("%w: part %d %q contains NUL", ErrInvalidName, i, p)
+		case strings.IndexByte(p, '\\') >= 0:
+			return "", fmt.Errorf(`%w: part %d %q contains '\'`, ErrInvalidName, i, p)
+		}
+		escaped[i] = nameEscaper.Replace(p)
+	}
+	name, err := namespace.qualify(strings.Join(escaped, nameSeparator))
+	if err != nil {
+		return "", err
+	}
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// MustBuildName 与 BuildName 相同，但在 BuildName 返回错误时 panic。它只应用于在代码中写定的 parts，
+// 例如初始化包级变量；parts 来自运行时的输入时应使用 BuildName。
+func MustBuildName(namespace Namespace, parts ...string) string {
+	name, err := BuildName(namespace, parts...)
+	if err != nil {
+		panic(err)
+	}
+	return name
+}
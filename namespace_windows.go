@@ -0,0 +1,27 @@
+package mutex
+
+// AcquireGlobal 在全局命名空间（Global\）中创建跨进程互斥锁，使锁在所有会话（服务与交互式登录）之间共享。
+// name 不应带有命名空间前缀，否则返回 ErrNamespacePrefixed。
+//
+// 在会话 0 以外的会话中创建全局对象需要 SeCreateGlobalPrivilege 特权，
+// 缺少该特权时返回的错误包装了 ErrInsufficientPrivilege 与 windows.ERROR_ACCESS_DENIED。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireGlobal(name string) (*Releaser, error) {
+	n, err := prefixNamespace(globalPrefix, name)
+	if err != nil {
+		return nil, err
+	}
+	return Acquire(n)
+}
+
+// AcquireLocal 在当前会话的命名空间（Local\）中创建跨进程互斥锁，锁只在同一会话的进程之间共享。
+// 这与不带前缀的 name 行为一致，只是显式地表明了意图。
+// name 不应带有命名空间前缀，否则返回 ErrNamespacePrefixed。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireLocal(name string) (*Releaser, error) {
+	n, err := prefixNamespace(localPrefix, name)
+	if err != nil {
+		return nil, err
+	}
+	return Acquire(n)
+}
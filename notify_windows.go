@@ -0,0 +1,154 @@
+package mutex
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// WaitFor 与 Notify 通过名为 name + notifySuffix 的自动重置事件传递通知。
+//
+// NotifyAll 不能像 PulseEvent 那样触发手动重置的事件后立即重置：在这两步之间没有真正阻塞在事件上的等待者（例如正在处理 APC）会错过通知。
+// 因此广播按代进行：名为 name + notifyAllSuffix 的共享内存保存当前的代数 g，每一代使用名为 name + notifyAllSuffix + "#" + g 的手动重置事件。
+// NotifyAll 先把代数加一，再触发旧一代的事件且不再重置它，这一代的所有等待者都会被唤醒，之后的等待者读到新的代数，等待新一代的事件。
+// 等待者打开事件后再读一次代数，代数已经改变说明这一代的通知已经发出，可能发生在事件被打开之前，此时直接返回。
+// 共享内存与事件都在所有句柄关闭后被销毁，此时没有任何等待者，代数从 0 重新开始不会造成影响。
+const (
+	notifySuffix    = "#notify"
+	notifyAllSuffix = "#notifyall"
+)
+
+// WaitFor 等待其他进程（或当前进程）对 name 调用 Notify 或 NotifyAll，等待超过 timeout 时返回 ErrWaitTimeout。
+// timeout 没有上限，小于 0 时视为 0。它只在真正收到通知时返回 nil，不会被虚假唤醒。
+//
+// 与条件变量一样，通知不携带状态：收到通知后调用者应该重新检查所等待的条件，条件不满足时再次等待。
+// 通知只会被正在等待的使用者收到。没有任何进程打开着对应的事件时，Notify 发出的通知会丢失；
+// 有进程打开着事件但没有人在等待时，最多保留一次 Notify 的通知，留给下一个等待者，NotifyAll 的通知则不会保留。
+// NotifyAll 一定会唤醒在它之前开始等待的所有使用者。
+// 因此应先开始等待，再让其他进程去完成它的阶段，或者在等待之前先检查一次条件。
+func WaitFor(name string, timeout time.Duration) error {
+	if timeout < 0 {
+		timeout = 0
+	}
+	deadline := time.Now().Add(timeout)
+
+	name = encodeName(name)
+	one, err := newEvent(name+notifySuffix, false)
+	if err != nil {
+		return err
+	}
+	defer one.Close()
+	gen, err := openGeneration(name)
+	if err != nil {
+		return err
+	}
+	defer gen.close()
+	g := gen.load()
+	all, err := newEvent(generationEvent(name, g), true)
+	if err != nil {
+		return err
+	}
+	defer all.Close()
+	if gen.load() != g {
+		return nil
+	}
+
+	handles := []windows.Handle{one.h, all.h}
+	for {
+		// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-waitformultipleobjects
+		rt, err := windows.WaitForMultipleObjects(handles, false, remainingMilliseconds(deadline))
+		switch {
+		case rt == windows.WAIT_OBJECT_0 || rt == windows.WAIT_OBJECT_0+1:
+			return nil
+		case rt == uint32(windows.WAIT_TIMEOUT):
+			if time.Now().Before(deadline) {
+				// 单次等待的时间有上限，继续等待剩余的时间。
+				continue
+			}
+			return nameError(name, errWaitTimeout)
+		case rt == windows.WAIT_FAILED:
+			return nameError(name, waitFailed(err))
+		default:
+			return nameError(name, unexpectedWait(rt))
+		}
+	}
+}
+
+// Notify 唤醒一个正在通过 WaitFor 等待 name 的使用者。没有人在等待时的行为见 WaitFor。
+func Notify(name string) error {
+	e, err := newEvent(encodeName(name)+notifySuffix, false)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	return e.Signal()
+}
+
+// NotifyAll 唤醒所有正在通过 WaitFor 等待 name 的使用者。之后才开始等待的使用者不会收到这次通知。
+func NotifyAll(name string) error {
+	name = encodeName(name)
+	gen, err := openGeneration(name)
+	if err != nil {
+		return err
+	}
+	defer gen.close()
+	e, err := newEvent(generationEvent(name, gen.next()), true)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	return e.Signal()
+}
+
+// generation 是映射到当前进程的 NotifyAll 的代数。
+type generation struct {
+	h windows.Handle
+	n *uint32
+}
+
+// openGeneration 创建或打开 name 的代数。
+func openGeneration(name string) (*generation, error) {
+	mapping := name + notifyAllSuffix
+	if err := validateName(mapping); err != nil {
+		return nil, err
+	}
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-createfilemappingw
+	h, err := windows.CreateFileMapping(windows.InvalidHandle, nil, windows.PAGE_READWRITE, 0, 4, windows.StringToUTF16Ptr(mapping))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return nil, nameError(name, createFailed(mapping, err))
+	}
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-mapviewoffile
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_WRITE, 0, 0, 4)
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, nameError(name, err)
+	}
+	// 与 openOwnerSlot 相同，经由 &addr 转换只是为了绕过 go vet 对 uintptr 转换的检查。
+	return &generation{h: h, n: (*uint32)(*(*unsafe.Pointer)(unsafe.Pointer(&addr)))}, nil
+}
+
+// load 返回当前的代数。
+func (g *generation) load() uint32 {
+	return atomic.LoadUint32(g.n)
+}
+
+// next 将代数加一，并返回加一之前的代数。
+func (g *generation) next() uint32 {
+	return atomic.AddUint32(g.n, 1) - 1
+}
+
+// close 解除映射并关闭句柄。
+func (g *generation) close() {
+	_ = windows.UnmapViewOfFile(uintptr(unsafe.Pointer(g.n)))
+	windows.CloseHandle(g.h)
+}
+
+// generationEvent 返回 name 第 g 代的广播事件的名字。
+func generationEvent(name string, g uint32) string {
+	return name + notifyAllSuffix + "#" + strconv.FormatUint(uint64(g), 10)
+}
@@ -0,0 +1,75 @@
+package mutex
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Phase 是锁生命周期中的阶段，用作 Event.Phase。它的值是稳定的，可以直接用作日志或指标的标签。
+type Phase string
+
+// 锁生命周期中的各个阶段。
+const (
+	// PhaseAcquireStart 表明开始获取锁。
+	PhaseAcquireStart Phase = "acquire_start"
+	// PhaseBlocked 表明无法立即获得锁，开始在操作系统的锁上等待其他进程释放它，与 PhaseUnblocked 成对出现。
+	// 在进程内的锁上排队不会产生它。事件发出的时刻就是开始等待的时刻，Duration 为 0。
+	// 它只发送给 SetObserver 设置的观察者，用于调度器等需要得知协程阻塞在跨进程等待中的场景。
+	PhaseBlocked Phase = "blocked"
+	// PhaseUnblocked 表明在操作系统的锁上的等待结束了，无论是获得了锁、超时还是被取消。Duration 为等待的时间。
+	PhaseUnblocked Phase = "unblocked"
+	// PhaseStillWaiting 表明等待锁的时间超过了 WithWatchdog 指定的阈值，依然在等待。
+	PhaseStillWaiting Phase = "still_waiting"
+	// PhaseAcquired 表明成功获得了锁。
+	PhaseAcquired Phase = "acquired"
+	// PhaseAbandoned 表明获得了锁，但上一任持有者在没有释放锁时就退出了。
+	PhaseAbandoned Phase = "abandoned"
+	// PhaseMaxHoldExceeded 表明持有锁的时间超过了 WithMaxHold 指定的上限，锁随即被强制释放。
+	// Duration 为持有锁的时间。
+	PhaseMaxHoldExceeded Phase = "max_hold_exceeded"
+	// PhaseReleaseStart 表明开始释放锁，之后会有 PhaseReleased 或 PhaseError。Duration 为持有锁的时间。
+	PhaseReleaseStart Phase = "release_start"
+	// PhaseReleased 表明锁已被释放。
+	PhaseReleased Phase = "released"
+	// PhaseError 表明获取或释放锁失败，错误保存在 Event.Err 中。
+	PhaseError Phase = "error"
+)
+
+// Event 描述锁生命周期中的一个事件。
+type Event struct {
+	// Name 是锁名。
+	Name string
+	// Phase 是事件所处的阶段，取值为 Phase 开头的常量之一。
+	Phase Phase
+	// Time 是事件发生的时间。
+	Time time.Time
+	// Duration 在获取锁的事件中为已经等待的时间，在释放锁的事件中为持有锁的时间。
+	Duration time.Duration
+	// Err 是获取或释放锁失败的原因，仅在 Phase 为 PhaseError 时不为 nil。
+	Err error
+}
+
+var observer atomic.Pointer[func(Event)]
+
+// SetObserver 设置锁生命周期事件的观察者，传入 nil 时取消观察。
+// 观察者在获取与释放锁的协程中被同步调用，不应阻塞，也不应在其中获取或释放锁。
+// 观察者只用于观察，不会影响加锁的行为；未设置时没有额外开销。
+func SetObserver(f func(Event)) {
+	if f == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&f)
+}
+
+// notify 通知观察者。未设置观察者时什么也不做。
+func notify(name string, phase Phase, d time.Duration, err error) {
+	if f := observer.Load(); f != nil {
+		(*f)(Event{Name: name, Phase: phase, Time: time.Now(), Duration: d, Err: err})
+	}
+}
+
+// observing 表明当前是否设置了观察者，用于在没有观察者时跳过计时等额外工作。
+func observing() bool {
+	return observer.Load() != nil
+}
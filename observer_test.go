@@ -0,0 +1,114 @@
+package mutex
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetObserver(t *testing.T) {
+	name := testName("set_observer")
+
+	var mu sync.Mutex
+	var events []Event
+	SetObserver(func(e Event) {
+		if e.Name != name {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	t.Cleanup(func() { SetObserver(nil) })
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := TryAcquire(name); ok {
+		t.Fatal("expect not acquired")
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var phases []Phase
+	for i, e := range events {
+		phases = append(phases, e.Phase)
+		if e.Time.IsZero() || i > 0 && e.Time.Before(events[i-1].Time) {
+			t.Fatalf("event %d: unexpected time %v", i, e.Time)
+		}
+	}
+	want := []Phase{PhaseAcquireStart, PhaseAcquired, PhaseAcquireStart, PhaseError, PhaseReleaseStart, PhaseReleased}
+	if !reflect.DeepEqual(phases, want) {
+		t.Fatalf("expect %v, got %v", want, phases)
+	}
+	if !errors.Is(events[3].Err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", events[3].Err)
+	}
+}
+
+func TestObserverTimeout(t *testing.T) {
+	name := testName("observer_timeout")
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	var events []Event
+	_, err = AcquireWithOptions(name,
+		WithTimeout(10*time.Millisecond),
+		WithObserver(func(e Event) { events = append(events, e) }),
+	)
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if len(events) != 2 || events[0].Phase != PhaseAcquireStart || events[1].Phase != PhaseError {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if e := events[1]; e.Name != name || !errors.Is(e.Err, ErrWaitTimeout) || e.Duration < 10*time.Millisecond || e.Time.Before(events[0].Time) {
+		t.Fatalf("unexpected error event %+v", e)
+	}
+}
+
+func TestObserverBlocked(t *testing.T) {
+	name := testName("observer_blocked")
+
+	var mu sync.Mutex
+	var events []Event
+	SetObserver(func(e Event) {
+		if e.Name != name || e.Phase != PhaseBlocked && e.Phase != PhaseUnblocked {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	t.Cleanup(func() { SetObserver(nil) })
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 进程内的竞争在进程内的锁上排队，不会等待操作系统的锁，这里直接调用 osAcquire。
+	if _, err := osAcquire(name, 100*time.Millisecond, nil); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0].Phase != PhaseBlocked || events[1].Phase != PhaseUnblocked {
+		t.Fatalf("expect blocked and unblocked, got %v", events)
+	}
+	if events[1].Duration < 50*time.Millisecond {
+		t.Fatalf("expect blocked for about 100ms, got %v", events[1].Duration)
+	}
+}
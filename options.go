@@ -0,0 +1,350 @@
+package mutex
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+)
+
+// Option 配置 AcquireWithOptions 的行为。
+//
+// opts 按传入的顺序依次应用，同一种选项传入多次时后面的覆盖前面的，例如
+// AcquireWithOptions(name, WithTimeout(time.Second), WithTimeout(0)) 的等待时间为 0。
+// 不同种类的选项互不影响，会同时生效。
+type Option func(*options)
+
+// options 是 AcquireWithOptions 的配置。
+type options struct {
+	timeout  time.Duration
+	timed    bool // 是否指定了 timeout
+	recovery func() error
+	observer func(Event)
+	poll     time.Duration
+	watchdog time.Duration
+	maxHold  time.Duration
+	fair     bool
+	acquired func(*Releaser) error
+	spin     int
+	ns       Namespace
+	os       osOptions // 只在部分平台上有意义的配置
+}
+
+// WithNamespace 在 namespace 指定的命名空间中创建锁，各个取值的含义见 Namespace。它与 BuildName 一样在所有平台上为 name 加上相同的前缀，
+// 因此同一段代码在各平台上使用相同的锁名；前缀只在 windows 下表示命名空间，其他平台上只是锁名的一部分。
+// namespace 不为 NamespaceDefault 时 name 不应带有命名空间前缀，否则返回 ErrNamespacePrefixed；
+// namespace 不是已定义的值时返回 ErrInvalidName。
+func WithNamespace(namespace Namespace) Option {
+	return func(o *options) {
+		o.ns = namespace
+	}
+}
+
+// WithTimeout 指定最长等待时间，与 AcquireWithTimeout 的 timeout 参数含义相同。未指定时使用 SetDefaultTimeout 设置的默认值，默认无限等待。
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+		o.timed = true
+	}
+}
+
+// WithRecovery 设置锁被遗弃时的恢复函数。
+// 获得的锁被遗弃时，recovery 在持有锁的情况下、AcquireWithOptions 返回之前被调用，用于检查并修复被保护的资源。
+// recovery 成功时加锁正常完成，返回的 Releaser 的 IsAbandoned 依然为 true，调用者可以据此得知恢复过程已经发生；
+// recovery 失败时锁会被释放，AcquireWithOptions 返回同时包装了 ErrAbandoned 与该错误的错误。
+// 注意锁一旦被获得，遗弃状态就被清除了，下一任持有者不会再看到 IsAbandoned 为 true。
+func WithRecovery(recovery func() error) Option {
+	return func(o *options) {
+		o.recovery = recovery
+	}
+}
+
+// WithOnAcquired 设置获得锁时的回调。f 在操作系统的锁被获得之后立即被调用：windows 下在持有互斥量的 worker 线程上、
+// WAIT_OBJECT_0 之后执行，unix 下在 flock 成功之后执行。它早于进程内的统计、登记、观察者的事件、WithRecovery 设置的恢复函数
+// 与 AcquireWithOptions 的返回，适合在持有锁的情况下尽早完成的簿记工作。
+// f 返回错误时锁随即被释放，这次加锁与其他失败的加锁一样计入统计并向观察者报告 PhaseError，
+// AcquireWithOptions 返回包装了该错误的错误。
+//
+// 交给 f 的 r 只在 f 执行期间有效，并且只能读取获得锁时的状态：Name、IsAbandoned、WasCreated、AcquiredAt 与 windows 下的 Handle。
+// 它不是 AcquireWithOptions 返回的 Releaser，释放它只会返回错误。
+// windows 下 f 会阻塞同一个 worker 线程上的其他加锁与释放，因此它必须很快完成，并且不能获取或释放本包的锁，否则可能死锁。
+func WithOnAcquired(f func(r *Releaser) error) Option {
+	return func(o *options) {
+		o.acquired = f
+	}
+}
+
+// errOnAcquiredRelease 是释放交给 WithOnAcquired 回调的 Releaser 时返回的错误。
+var errOnAcquiredRelease = errors.New("mutex release: the Releaser passed to the on acquired callback cannot be released")
+
+// runOnAcquired 以获得锁时的状态调用 WithOnAcquired 设置的回调 f，返回包装了 f 的错误的错误。
+func runOnAcquired(f func(*Releaser) error, name string, abandoned, created bool, handle uintptr) error {
+	r := &Releaser{
+		isAbandoned: abandoned,
+		created:     created,
+		handle:      handle,
+		lease: &lease{
+			name:       name,
+			acquiredAt: time.Now(),
+			release:    func() error { return errOnAcquiredRelease },
+		},
+	}
+	if err := f(r.pin()); err != nil {
+		return fmt.Errorf("on acquired: %w", err)
+	}
+	return nil
+}
+
+// withOnAcquired 返回在 f 获得锁后立即调用回调 hook 的 acquireFunc。hook 返回错误时释放锁并返回该错误。
+// 它用于获得锁的协程就是调用者的协程的平台，windows 下的回调由 worker 调用，见 object。
+func withOnAcquired(f acquireFunc, hook func(*Releaser) error) acquireFunc {
+	if hook == nil {
+		return f
+	}
+	return func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		r, err := f(name, timeout, done)
+		if err != nil {
+			return nil, err
+		}
+		if err := runOnAcquired(hook, name, r.isAbandoned, r.created, r.handle); err != nil {
+			_ = r.release()
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// WithObserver 设置只观察这一次加锁及其释放的观察者，它与 SetObserver 设置的全局观察者互不影响，两者都会收到事件。
+// 与全局观察者一样，f 在获取与释放锁的协程中被同步调用，不应阻塞。
+func WithObserver(f func(Event)) Option {
+	return func(o *options) {
+		o.observer = f
+	}
+}
+
+// WithPollInterval 指定轮询锁状态的间隔，只对基于轮询实现等待的平台有效。
+// unix 下 flock(2) 不支持超时，带超时或可取消的等待只能轮询，默认间隔为 50 毫秒，每次的实际间隔带有随机抖动。
+// 间隔越短获得锁的延迟越低，但占用的 CPU 越多。windows 等原生支持超时等待的平台上该选项没有任何效果。
+// interval 不大于 0 时使用默认值。
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.poll = interval
+	}
+}
+
+// WithWatchdog 开启等待看门狗：等待 threshold 后依然没有获得锁时，向观察者发送 Phase 为 PhaseStillWaiting、
+// Duration 为已经等待的时间的事件，之后每隔 threshold 重复一次，直到获得锁或获取失败。
+// 长时间卡住的等待通常意味着死锁，看门狗使它可以被观察到，但不会中止等待。
+// 事件同时发送给 SetObserver 设置的全局观察者与 WithObserver 设置的观察者，它们在看门狗的协程中被调用。
+// threshold 不大于 0 时不开启，默认不开启。
+func WithWatchdog(threshold time.Duration) Option {
+	return func(o *options) {
+		o.watchdog = threshold
+	}
+}
+
+// startWatchdog 从 start 起每隔 threshold 报告一次依然在等待 name。
+// 返回的函数停止报告，并等待正在进行的报告结束，使之后的事件不会与它乱序。
+func startWatchdog(name string, start time.Time, threshold time.Duration, observer func(Event)) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		t := time.NewTicker(threshold)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				d := now.Sub(start)
+				notify(name, PhaseStillWaiting, d, nil)
+				if observer != nil {
+					observer(Event{Name: name, Phase: PhaseStillWaiting, Time: now, Duration: d})
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// WithMaxHold 指定持有锁的最长时间：获得锁后 d 内没有调用 Release 时，本包向观察者发送 Phase 为 PhaseMaxHoldExceeded 的事件、
+// 打印日志，然后自行释放锁，之后再调用 Release 会返回 ErrAlreadyReleased。
+// 它是防止忘记释放锁的最后手段，默认不开启，d 不大于 0 时同样不开启。通过 Transfer 转交后的 Releaser 不再受它限制。
+//
+// 注意强制释放时持有者可能依然在临界区中，其他进程随即可以获得锁并进入临界区，互斥不再成立。
+// 只应在宁可破坏互斥也不能让锁被永久占用的场景中使用它，并把它触发视为需要修复的错误。
+func WithMaxHold(d time.Duration) Option {
+	return func(o *options) {
+		o.maxHold = d
+	}
+}
+
+// limitHold 在 r 被持有超过 d 时强制释放它。
+func limitHold(r *Releaser, d time.Duration, observer func(Event)) {
+	// 不能引用 r，见 lease。
+	l := r.lease
+	released := make(chan struct{})
+	release := l.release
+	l.release = func() error {
+		close(released)
+		return release()
+	}
+
+	go func() {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-released:
+			return
+		case <-t.C:
+		}
+		if l.released.Load() {
+			// 已经通过 Transfer 转交给了其他 Releaser。
+			return
+		}
+
+		held := time.Since(l.acquiredAt)
+		notify(l.name, PhaseMaxHoldExceeded, held, nil)
+		if observer != nil {
+			observer(Event{Name: l.name, Phase: PhaseMaxHoldExceeded, Time: time.Now(), Duration: held})
+		}
+		log.Printf("mutex: %q held for %v, exceeding the maximum hold time %v, releasing it now", l.name, held, d)
+		if err := l.releaseUntil(nil, nil); err != nil && !errors.Is(err, ErrAlreadyReleased) {
+			log.Printf("mutex: forced release of %q failed: %v", l.name, err)
+		}
+	}()
+}
+
+// WithLocalFairness 为 true 时，同一进程内开启了该选项的等待者按到达的顺序依次获得锁，默认不开启。
+// 它只约束同样开启了该选项的使用者，没有开启的使用者不排队，可能先于排队中的等待者获得锁。
+// 跨进程的顺序依然由操作系统决定。
+func WithLocalFairness(fair bool) Option {
+	return func(o *options) {
+		o.fair = fair
+	}
+}
+
+// WithSpin 指定在阻塞等待之前，先不等待地尝试获取锁最多 iterations 次，每两次之间让出处理器。
+// 临界区很短时，持有者往往在几微秒内就会释放锁，此时进入内核等待与被唤醒的开销比等待本身还大，自旋可以明显降低获得锁的延迟。
+// 代价是自旋期间一直占用 CPU：持有者持有锁的时间较长或竞争的使用者很多时，自旋只会白白消耗 CPU，之后依然要阻塞等待。
+// 自旋所花的时间计入等待时间，等待时间为 0 时不自旋。iterations 不大于 0 时不自旋，默认不自旋。
+func WithSpin(iterations int) Option {
+	return func(o *options) {
+		o.spin = iterations
+	}
+}
+
+// withSpin 返回先以 0 为 timeout 调用 f 最多 iterations 次、都没有获得锁时再正常调用 f 的 acquireFunc。
+func withSpin(f acquireFunc, iterations int) acquireFunc {
+	return func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		if timeout == 0 {
+			return f(name, timeout, done)
+		}
+		start := time.Now()
+		for i := 0; i < iterations; i++ {
+			r, err := f(name, 0, done)
+			if !errors.Is(err, ErrWaitTimeout) {
+				return r, err
+			}
+			select {
+			case <-done:
+				return nil, ErrCanceled
+			default:
+			}
+			runtime.Gosched()
+		}
+		if timeout != waitForever {
+			timeout -= time.Since(start)
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+		return f(name, timeout, done)
+	}
+}
+
+// AcquireWithOptions 按 opts 创建跨进程互斥锁。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithOptions(name string, opts ...Option) (*Releaser, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	timeout := defaultWait()
+	if o.timed {
+		timeout = o.timeout
+		if timeout < 0 {
+			timeout = 0
+		}
+	}
+
+	name, err := o.ns.qualify(encodeName(name))
+	if err != nil {
+		return nil, err
+	}
+	name, f, err := o.os.prepare(name, o.poll, o.acquired)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.observer != nil {
+		o.observer(Event{Name: name, Phase: PhaseAcquireStart, Time: time.Now()})
+	}
+	start := time.Now()
+	stop := func() {}
+	if o.watchdog > 0 {
+		stop = startWatchdog(name, start, o.watchdog, o.observer)
+	}
+	f = withLocal(f)
+	if o.fair {
+		f = withFair(f)
+	}
+	if o.spin > 0 {
+		f = withSpin(f, o.spin)
+	}
+	r, err := acquireWith(f, name, timeout, nil)
+	stop()
+	if err == nil && r.isAbandoned && o.recovery != nil {
+		if e := o.recovery(); e != nil {
+			_ = r.Release()
+			r, err = nil, nameError(name, fmt.Errorf("%w: recovery: %w", ErrAbandoned, e))
+		}
+	}
+	if err != nil {
+		if o.observer != nil {
+			o.observer(Event{Name: name, Phase: PhaseError, Time: time.Now(), Duration: time.Since(start), Err: err})
+		}
+		return nil, err
+	}
+
+	if o.observer != nil {
+		phase := PhaseAcquired
+		if r.isAbandoned {
+			phase = PhaseAbandoned
+		}
+		o.observer(Event{Name: name, Phase: phase, Time: r.acquiredAt, Duration: r.acquiredAt.Sub(start)})
+
+		// 不能引用 r，见 lease。
+		release, acquiredAt := r.release, r.acquiredAt
+		r.release = func() error {
+			o.observer(Event{Name: name, Phase: PhaseReleaseStart, Time: time.Now(), Duration: time.Since(acquiredAt)})
+			err := release()
+			phase := PhaseReleased
+			if err != nil {
+				phase = PhaseError
+			}
+			now := time.Now()
+			o.observer(Event{Name: name, Phase: phase, Time: now, Duration: now.Sub(acquiredAt), Err: err})
+			return err
+		}
+	}
+	if o.maxHold > 0 {
+		limitHold(r, o.maxHold, o.observer)
+	}
+	return r, nil
+}
@@ -0,0 +1,335 @@
+package mutex
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireWithOptions(t *testing.T) {
+	name := testName("acquire_with_options")
+
+	var phases []Phase
+	r, err := AcquireWithOptions(name,
+		WithRecovery(func() error {
+			t.Fatal("recovery must not run when the lock is not abandoned")
+			return nil
+		}),
+		WithObserver(func(e Event) { phases = append(phases, e.Phase) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 后传入的 WithTimeout 覆盖前面的。
+	r2, err := AcquireWithOptions(name, WithTimeout(time.Hour), WithTimeout(0))
+	if !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r2.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []Phase{PhaseAcquireStart, PhaseAcquired, PhaseReleaseStart, PhaseReleased}
+	if !reflect.DeepEqual(phases, expect) {
+		t.Fatalf("expect %v, got %v", expect, phases)
+	}
+}
+
+func TestWithWatchdog(t *testing.T) {
+	name := testName("with_watchdog")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, func() { _ = r.Release() })
+
+	var mu sync.Mutex
+	var events []Event
+	r2, err := AcquireWithOptions(name,
+		WithWatchdog(20*time.Millisecond),
+		WithObserver(func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r2.Release()
+
+	mu.Lock()
+	defer mu.Unlock()
+	n := len(events)
+	if n < 5 || events[0].Phase != PhaseAcquireStart || events[n-3].Phase != PhaseAcquired || events[n-2].Phase != PhaseReleaseStart || events[n-1].Phase != PhaseReleased {
+		t.Fatalf("unexpected events %v", events)
+	}
+	var last time.Duration
+	for _, e := range events[1 : n-3] {
+		if e.Phase != PhaseStillWaiting || e.Name != name || e.Duration <= last {
+			t.Fatalf("unexpected events %v", events)
+		}
+		last = e.Duration
+	}
+}
+
+func TestWithMaxHold(t *testing.T) {
+	name := testName("with_max_hold")
+
+	var mu sync.Mutex
+	var phases []Phase
+	r, err := AcquireWithOptions(name,
+		WithMaxHold(50*time.Millisecond),
+		WithObserver(func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			phases = append(phases, e.Phase)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 锁被强制释放后其他使用者可以获得它。
+	r2, err := AcquireWithTimeout(name, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Release()
+	if err := r.Release(); !errors.Is(err, ErrAlreadyReleased) {
+		t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expect := []Phase{PhaseAcquireStart, PhaseAcquired, PhaseMaxHoldExceeded, PhaseReleaseStart, PhaseReleased}
+	if !reflect.DeepEqual(phases, expect) {
+		t.Fatalf("expect %v, got %v", expect, phases)
+	}
+
+	// 按时释放时不会被强制释放。
+	r3, err := AcquireWithOptions(testName("with_max_hold_in_time"), WithMaxHold(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r3.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithLocalFairness(t *testing.T) {
+	name := testName("with_local_fairness")
+	testFairOrder(t, name, func(int) string { return name })
+
+	// 等待超时的使用者离开队列，不影响之后的使用者。
+	r, err := AcquireWithOptions(name, WithLocalFairness(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireWithOptions(name, WithLocalFairness(true), WithTimeout(10*time.Millisecond)); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = AcquireWithOptions(name, WithLocalFairness(true), WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testFairOrder 在 name 被持有时依次让 5 个使用者以 WithLocalFairness 排队，第 i 个使用者使用 alias(i) 作为名字，
+// 并检查它们按到达的顺序获得锁。
+func testFairOrder(t *testing.T, name string, alias func(i int) string) {
+	t.Helper()
+	r, err := AcquireWithOptions(name, WithLocalFairness(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 5
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := AcquireWithOptions(alias(i), WithLocalFairness(true))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			if err := r.Release(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+
+		// 等到第 i 个等待者开始排队后再启动下一个，使到达的顺序确定。
+		for {
+			w, err := WaitersFor(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	expect := []int{0, 1, 2, 3, 4}
+	if !reflect.DeepEqual(order, expect) {
+		t.Fatalf("expect %v, got %v", expect, order)
+	}
+}
+
+func TestWithOnAcquired(t *testing.T) {
+	name := testName("with_on_acquired")
+
+	var gotName string
+	var phases []Phase
+	r, err := AcquireWithOptions(name,
+		WithOnAcquired(func(r *Releaser) error {
+			gotName = r.Name()
+			// 回调早于观察者的事件。
+			phases = append(phases, "callback")
+			if err := r.Release(); err == nil {
+				t.Error("expect the callback's releaser cannot be released")
+			}
+			return nil
+		}),
+		WithObserver(func(e Event) { phases = append(phases, e.Phase) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotName != r.Name() {
+		t.Fatalf("expect %q, got %q", r.Name(), gotName)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []Phase{PhaseAcquireStart, "callback", PhaseAcquired, PhaseReleaseStart, PhaseReleased}; !reflect.DeepEqual(phases, expect) {
+		t.Fatalf("expect %v, got %v", expect, phases)
+	}
+
+	// 回调失败时这次加锁是失败的加锁，不计入持有者。
+	injected := errors.New("injected")
+	before := Stats()
+	phases = nil
+	_, err = AcquireWithOptions(name,
+		WithOnAcquired(func(r *Releaser) error { return injected }),
+		WithObserver(func(e Event) { phases = append(phases, e.Phase) }),
+	)
+	if !errors.Is(err, injected) {
+		t.Fatalf("expect injected, got %v", err)
+	}
+	if expect := []Phase{PhaseAcquireStart, PhaseError}; !reflect.DeepEqual(phases, expect) {
+		t.Fatalf("expect %v, got %v", expect, phases)
+	}
+	if after := Stats(); after.Acquisitions != before.Acquisitions {
+		t.Fatalf("expect %d acquisitions, got %d", before.Acquisitions, after.Acquisitions)
+	}
+
+	// 回调失败时锁已经被释放。
+	r, err = AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithSpin(t *testing.T) {
+	name := testName("with_spin")
+	r, err := AcquireWithOptions(name, WithSpin(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 自旋都失败后依然按 timeout 等待。
+	if _, err := AcquireWithOptions(name, WithSpin(10), WithTimeout(10*time.Millisecond)); !errors.Is(err, ErrWaitTimeout) {
+		_ = r.Release()
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = r.Release()
+	}()
+	r, err = AcquireWithOptions(name, WithSpin(10), WithTimeout(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkSpin(b *testing.B) {
+	for _, spin := range []int{0, 100} {
+		b.Run(fmt.Sprintf("spin=%d", spin), func(b *testing.B) {
+			name := testName(fmt.Sprintf("benchmark_spin_%d", spin))
+			for i := 0; i < b.N; i++ {
+				h, err := Acquire(name)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// 持有者在几微秒后释放锁。
+				go func() {
+					for start := time.Now(); time.Since(start) < 5*time.Microsecond; {
+					}
+					_ = h.Release()
+				}()
+				r, err := AcquireWithOptions(name, WithSpin(spin))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := r.Release(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithNamespacePortable(t *testing.T) {
+	name := testName("with_namespace_portable")
+	t.Cleanup(func() { _ = Purge(`Local\` + name) })
+
+	r, err := AcquireWithOptions(name, WithNamespace(NamespaceLocal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	if expect := MustBuildName(NamespaceLocal, name); r.Name() != expect {
+		t.Fatalf("expect %q, got %q", expect, r.Name())
+	}
+
+	if _, err := AcquireWithOptions(`Local\`+name, WithNamespace(NamespaceGlobal)); !errors.Is(err, ErrNamespacePrefixed) {
+		t.Fatalf("expect ErrNamespacePrefixed, got %v", err)
+	}
+	if _, err := AcquireWithOptions(name, WithNamespace(Namespace(-1))); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import "time"
+
+// osOptions 是只在部分平台上有意义的配置。unix 下没有这样的配置。
+type osOptions struct{}
+
+// prepare 返回实际使用的锁名与获取锁的函数。pollInterval 为 0 时使用默认的轮询间隔。
+// onAcquired 不为 nil 时在获得锁后立即被调用，见 WithOnAcquired。
+func (o *osOptions) prepare(name string, pollInterval time.Duration, onAcquired func(*Releaser) error) (string, acquireFunc, error) {
+	f := osAcquire
+	if pollInterval > 0 {
+		f = pollAcquire(pollInterval)
+	}
+	return name, withOnAcquired(f, onAcquired), nil
+}
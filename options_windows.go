@@ -0,0 +1,62 @@
+package mutex
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// osOptions 是只在 windows 上有意义的配置。
+type osOptions struct {
+	sddl    string
+	inherit bool
+}
+
+// WithSecurity 使用 SDDL 字符串描述的安全描述符创建锁，效果与 AcquireWithSecurity 相同。
+func WithSecurity(sddl string) Option {
+	return func(o *options) {
+		o.os.sddl = sddl
+	}
+}
+
+// WithInheritHandle 指定锁的句柄是否可以被子进程继承（bInheritHandle），默认不可继承。
+// 获得锁后通过 Releaser.Handle 得到句柄的值，把它交给子进程，子进程使用 FromInheritedHandle 获得同一个互斥量，
+// 不需要再按名字打开它，因此不存在名字被抢先占用的窗口。
+//
+// 句柄只有在创建子进程时指定继承才会被继承：使用 os/exec 时把句柄加入 cmd.SysProcAttr.AdditionalInheritedHandles，
+// 直接调用 CreateProcess 时 bInheritHandles 须为 TRUE；句柄的值在子进程中保持不变，通常通过命令行参数或环境变量告诉子进程。
+// 继承的只是句柄，而不是锁：互斥量属于获得它的线程，子进程需要等到父进程释放锁后才能获得它。
+// 继承性属于句柄而不是对象，打开已存在的互斥量时同样生效。
+func WithInheritHandle(inherit bool) Option {
+	return func(o *options) {
+		o.os.inherit = inherit
+	}
+}
+
+// prepare 返回实际使用的锁名与获取锁的函数。当前平台不需要轮询，pollInterval 被忽略。
+// onAcquired 不为 nil 时由 worker 在获得互斥量后立即调用，见 WithOnAcquired。
+func (o *osOptions) prepare(name string, pollInterval time.Duration, onAcquired func(*Releaser) error) (string, acquireFunc, error) {
+	if o.sddl == "" && !o.inherit && onAcquired == nil {
+		return name, osAcquire, nil
+	}
+	var sa *windows.SecurityAttributes
+	var err error
+	if o.sddl != "" {
+		sa, err = securityAttributes(o.sddl)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if o.inherit {
+		if sa == nil {
+			sa = &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{}))}
+		}
+		sa.InheritHandle = 1
+	}
+	obj := newMutexObject(sa)
+	obj.onAcquired = onAcquired
+	return name, func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		return acquireMutex(obj, sa, name, timeout, done)
+	}, nil
+}
@@ -0,0 +1,20 @@
+module github.com/kvii/mutex/otelmutex
+
+go 1.20
+
+require (
+	github.com/kvii/mutex v0.0.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
+
+// 仓库内开发时使用同一份 mutex 的代码。发布时 require 的版本应更新为 mutex 的正式版本。
+replace github.com/kvii/mutex => ../
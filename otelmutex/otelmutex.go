@@ -0,0 +1,54 @@
+// Package otelmutex 将 mutex 的加锁过程记录为 OpenTelemetry span。
+// 它通过 mutex.WithObserver 接入。otelmutex 是独立的 module，mutex 本身及其使用者都不依赖 OpenTelemetry。
+package otelmutex
+
+import (
+	"context"
+
+	"github.com/kvii/mutex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// span 的名字与属性。
+const (
+	spanName = "mutex.acquire"
+
+	// NameKey 是锁名。
+	NameKey = attribute.Key("mutex.name")
+	// WaitKey 是等待锁的时间，单位为毫秒。
+	WaitKey = attribute.Key("mutex.wait_ms")
+	// OutcomeKey 是加锁的结果，取值为 mutex.PhaseAcquired、mutex.PhaseAbandoned 或 mutex.PhaseError。
+	OutcomeKey = attribute.Key("mutex.outcome")
+)
+
+// WithTracer 返回一个 mutex.Option，它在开始获取锁时以 ctx 中的 span 为父 span 启动一个 span，
+// 并在获得锁或获取失败时结束它，记录锁名、等待时间与结果。获取失败时 span 的状态为 codes.Error。
+//
+// 每次加锁都应该使用新的 WithTracer，它记录的是这一次加锁的 span。
+// 全局的 mutex.SetObserver 无法区分同名锁的并发加锁，因此没有提供基于它的接入方式。
+func WithTracer(ctx context.Context, tracer trace.Tracer) mutex.Option {
+	var span trace.Span
+	return mutex.WithObserver(func(e mutex.Event) {
+		switch e.Phase {
+		case mutex.PhaseAcquireStart:
+			_, span = tracer.Start(ctx, spanName, trace.WithAttributes(NameKey.String(e.Name)))
+		case mutex.PhaseAcquired, mutex.PhaseAbandoned, mutex.PhaseError:
+			if span == nil {
+				// 释放失败同样是 PhaseError，此时 span 已经结束了。
+				return
+			}
+			span.SetAttributes(
+				WaitKey.Float64(float64(e.Duration.Microseconds())/1000),
+				OutcomeKey.String(string(e.Phase)),
+			)
+			if e.Err != nil {
+				span.RecordError(e.Err)
+				span.SetStatus(codes.Error, e.Err.Error())
+			}
+			span.End()
+			span = nil
+		}
+	})
+}
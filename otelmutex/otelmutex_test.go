@@ -0,0 +1,51 @@
+package otelmutex
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kvii/mutex"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracer(t *testing.T) {
+	const name = "kvii_mutex_test_otelmutex_with_tracer"
+	t.Cleanup(func() { _ = mutex.Purge(name) })
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	ctx := context.Background()
+
+	r, err := mutex.AcquireWithOptions(name, WithTracer(ctx, tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+
+	_, err = mutex.AcquireWithOptions(name, WithTracer(ctx, tracer), mutex.WithTimeout(0))
+	if !errors.Is(err, mutex.ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans, got %d", len(spans))
+	}
+	for i, expect := range []mutex.Phase{mutex.PhaseAcquired, mutex.PhaseError} {
+		var outcome string
+		for _, kv := range spans[i].Attributes() {
+			if kv.Key == OutcomeKey {
+				outcome = kv.Value.AsString()
+			}
+		}
+		if outcome != string(expect) {
+			t.Fatalf("span %d: expect outcome %q, got %q", i, expect, outcome)
+		}
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Fatalf("expect error status, got %v", spans[1].Status().Code)
+	}
+}
@@ -0,0 +1,105 @@
+package mutex
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ownerSuffix 是记录持有者 PID 的共享内存的名字后缀。
+const ownerSuffix = "#owner"
+
+// WAIT_ABANDONED 不会告知上一任持有者是谁。为了提供 AbandonedByPID，每个互斥量都伴随一块名为 name + ownerSuffix 的共享内存，
+// 持有者在获得锁后把自己的 PID 写入其中，并在释放前清空它；获得遗弃的锁时其中残留的 PID 就是遗弃它的进程。
+// 共享内存在所有句柄关闭后被销毁，等待者在开始等待之前就打开它，使持有者崩溃后记录依然保留。
+// 这依赖所有持有者都使用本包加锁，共享内存无法创建（例如名字过长或没有权限）时不记录 PID。
+
+// ownerSlot 是映射到当前进程的持有者记录。
+type ownerSlot struct {
+	h   windows.Handle
+	pid *uint32
+}
+
+// openOwnerSlot 创建或打开 name 的持有者记录，sa 为 nil 时使用默认安全描述符。失败时返回 nil。
+func openOwnerSlot(name string, sa *windows.SecurityAttributes) *ownerSlot {
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-createfilemappingw
+	h, err := windows.CreateFileMapping(windows.InvalidHandle, sa, windows.PAGE_READWRITE, 0, 4, windows.StringToUTF16Ptr(name+ownerSuffix))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return nil
+	}
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/memoryapi/nf-memoryapi-mapviewoffile
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_WRITE, 0, 0, 4)
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil
+	}
+	// 映射的内存不受垃圾回收器管理，经由 &addr 转换只是为了绕过 go vet 对 uintptr 转换的检查。
+	return &ownerSlot{h: h, pid: (*uint32)(*(*unsafe.Pointer)(unsafe.Pointer(&addr)))}
+}
+
+// own 将当前进程记录为 r 的持有者，并在 r 被遗弃时保存记录中残留的 PID。
+// r 的 release 在释放锁之前清空记录，并在释放之后关闭 s。s 为 nil 时什么也不做。
+func (s *ownerSlot) own(r *Releaser) {
+	if s == nil {
+		return
+	}
+	stale := atomic.SwapUint32(s.pid, uint32(os.Getpid()))
+	if r.isAbandoned {
+		r.abandonedBy = int(stale)
+	}
+	release := r.release
+	r.release = func() error {
+		atomic.StoreUint32(s.pid, 0)
+		err := release()
+		s.close()
+		return err
+	}
+}
+
+// close 解除映射并关闭句柄。s 为 nil 时什么也不做。
+func (s *ownerSlot) close() {
+	if s == nil {
+		return
+	}
+	_ = windows.UnmapViewOfFile(uintptr(unsafe.Pointer(s.pid)))
+	windows.CloseHandle(s.h)
+}
+
+// acquireMutex 与 acquireObject 相同，但在等待期间打开 name 的持有者记录，并在获得锁后记录持有者。
+// obj 必须是互斥量，sa 是创建持有者记录时使用的安全属性。
+// timeout 为 0 时先在当前协程中探测互斥量，见 probeMutex。
+func acquireMutex(obj object, sa *windows.SecurityAttributes, name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	var probeCreated bool
+	if timeout == 0 {
+		h, created, err := probeMutex(obj, name)
+		if err != nil {
+			return nil, err
+		}
+		// 探测的句柄保持打开，直到 worker 也打开了同一个互斥量，使它不会在这期间被销毁后重新创建。
+		defer windows.CloseHandle(h)
+		probeCreated = created
+		if hook := obj.onAcquired; hook != nil && created {
+			// worker 打开的是探测时创建的互斥量，回调看到的 WasCreated 应与返回的 Releaser 一致。
+			obj.onAcquired = func(r *Releaser) error {
+				r.created = true
+				return hook(r)
+			}
+		}
+	}
+
+	s := openOwnerSlot(name, sa)
+	r, err := acquireObject(obj, name, timeout, done)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	r.created = r.created || probeCreated
+	s.own(r)
+	return r, nil
+}
@@ -0,0 +1,286 @@
+package mutex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperEnv 不为空时，测试程序作为跨进程测试的子进程运行，见 runHelper。
+const helperEnv = "KVII_MUTEX_TEST_HELPER"
+
+// runHelper 是子进程的主函数。它从标准输入逐行读取命令，并把每个命令的结果作为一行写到标准输出：
+//   - acquire <name> <timeout>：获取锁，timeout 为 forever 时无限等待。结果为 acquired、timeout 或 error <原因>。
+//   - release：释放持有的锁。结果为 released 或 error <原因>。
+//   - exit：不释放锁就退出。
+//
+// 标准输入被关闭时同样不释放锁就退出。
+func runHelper() int {
+	var r *Releaser
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		args := strings.Fields(in.Text())
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "acquire":
+			timeout := waitForever
+			if args[2] != "forever" {
+				d, err := time.ParseDuration(args[2])
+				if err != nil {
+					fmt.Println("error", err)
+					continue
+				}
+				timeout = d
+			}
+			var err error
+			if timeout == waitForever {
+				r, err = Acquire(args[1])
+			} else {
+				r, err = AcquireWithTimeout(args[1], timeout)
+			}
+			switch {
+			case errors.Is(err, ErrWaitTimeout):
+				fmt.Println("timeout")
+			case err != nil:
+				fmt.Println("error", err)
+			default:
+				fmt.Println("acquired")
+			}
+		case "release":
+			if err := r.Release(); err != nil {
+				fmt.Println("error", err)
+				continue
+			}
+			fmt.Println("released")
+		case "exit":
+			return 0
+		default:
+			fmt.Println("error unknown command", args[0])
+		}
+	}
+	return 0
+}
+
+// child 是运行 runHelper 的子进程。
+type child struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Scanner
+}
+
+// startChild 以子进程的方式再次运行当前的测试程序。测试结束时子进程会被杀死。
+func startChild(t *testing.T) *child {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), helperEnv+"=1")
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	c := &child{cmd: cmd, in: in, out: bufio.NewScanner(out)}
+	t.Cleanup(c.kill)
+	return c
+}
+
+// do 将命令 line 交给子进程，返回它的结果。
+func (c *child) do(t *testing.T, line string) string {
+	t.Helper()
+	if _, err := fmt.Fprintln(c.in, line); err != nil {
+		t.Fatal(err)
+	}
+	if !c.out.Scan() {
+		t.Fatalf("child exited before replying to %q: %v", line, c.out.Err())
+	}
+	return c.out.Text()
+}
+
+// kill 杀死子进程并等待它退出。子进程来不及释放它持有的锁。
+func (c *child) kill() {
+	if c.cmd.ProcessState != nil {
+		return
+	}
+	_ = c.cmd.Process.Kill()
+	_ = c.cmd.Wait()
+}
+
+// exit 使子进程不释放锁就调用 os.Exit 退出，并等待它退出。
+func (c *child) exit(t *testing.T) {
+	t.Helper()
+	if _, err := fmt.Fprintln(c.in, "exit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect 断言子进程对 line 的结果为 want。
+func (c *child) expect(t *testing.T, line, want string) {
+	t.Helper()
+	if got := c.do(t, line); got != want {
+		t.Fatalf("%q: expect %q, got %q", line, want, got)
+	}
+}
+
+func TestCrossProcess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip spawning a child process in short mode")
+	}
+	name := testName("cross_process")
+	c := startChild(t)
+
+	// 子进程持有锁时，父进程无法获得它。
+	c.expect(t, "acquire "+name+" forever", "acquired")
+	if r, ok, err := TryAcquire(name); err != nil || ok {
+		if ok {
+			_ = r.Release()
+		}
+		t.Fatalf("expect held by the child, got %v %v", ok, err)
+	}
+	if _, err := AcquireWithTimeout(name, 100*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	// 父进程一直阻塞，直到子进程释放锁。
+	ch := make(chan *Releaser, 1)
+	go func() {
+		r, err := AcquireWithTimeout(name, 5*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- r
+	}()
+	select {
+	case <-ch:
+		t.Fatal("expect blocking while the child holds the lock")
+	case <-time.After(200 * time.Millisecond):
+	}
+	c.expect(t, "release", "released")
+	r := <-ch
+	if r == nil {
+		return
+	}
+	if r.IsAbandoned() {
+		t.Fatal("expect not abandoned")
+	}
+
+	// 父进程持有锁时，子进程等待超时。
+	c.expect(t, "acquire "+name+" 100ms", "timeout")
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCrossProcessKilled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip spawning a child process in short mode")
+	}
+	if !SupportsAbandoned() {
+		t.Skip("abandoned detection is not supported")
+	}
+	name := testName("cross_process_killed")
+	c := startChild(t)
+	c.expect(t, "acquire "+name+" forever", "acquired")
+
+	// 在杀死子进程之前就开始等待：windows 下没有进程打开互斥量时它会被销毁，遗弃状态随之消失。
+	ch := make(chan *Releaser, 1)
+	go func() {
+		r, err := AcquireWithTimeout(name, 10*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- r
+	}()
+	waitForWaiters(t, name, 1)
+
+	pid := c.cmd.Process.Pid
+	c.kill()
+	r := <-ch
+	if r == nil {
+		return
+	}
+	defer r.Release()
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned after the child was killed")
+	}
+	if got, ok := r.AbandonedByPID(); !ok || got != pid {
+		t.Fatalf("expect abandoned by %d, got %d %v", pid, got, ok)
+	}
+}
+
+func TestCrossProcessExit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip spawning a child process in short mode")
+	}
+	if !SupportsAbandoned() {
+		t.Skip("abandoned detection is not supported")
+	}
+	name := testName("cross_process_exit")
+	c := startChild(t)
+	// 子进程回复 acquired 时已经持有了锁。
+	c.expect(t, "acquire "+name+" forever", "acquired")
+
+	// 见 TestCrossProcessKilled。
+	ch := make(chan *Releaser, 1)
+	go func() {
+		r, err := AcquireWithTimeout(name, 10*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- r
+	}()
+	waitForWaiters(t, name, 1)
+
+	pid := c.cmd.Process.Pid
+	c.exit(t)
+	r := <-ch
+	if r == nil {
+		return
+	}
+	defer r.Release()
+	if !r.IsAbandoned() {
+		t.Fatal("expect abandoned after the child exited without releasing")
+	}
+	if got, ok := r.AbandonedByPID(); !ok || got != pid {
+		t.Fatalf("expect abandoned by %d, got %d %v", pid, got, ok)
+	}
+}
+
+// waitForWaiters 等待 name 的等待者数量达到 n。
+func waitForWaiters(t *testing.T, name string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w, err := WaitersFor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d waiters, got %d", n, w)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
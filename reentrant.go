@@ -0,0 +1,146 @@
+package mutex
+
+import (
+	"sync"
+	"time"
+)
+
+// ReentrantLocker 是可重入的锁：同一个持有者重复获取同名的锁时不会阻塞，
+// 只有第一次获取时才会真正获取跨进程锁，最后一次释放时才会真正释放。
+// 可重入只在当前进程内有效，其他进程看到的始终是一个被持有的跨进程锁。
+//
+// go 没有公开的协程标识，因此持有者由调用者通过 AcquireFor 的 owner 显式指定，例如一个请求或一个任务。
+// 不同的 owner 之间互斥，与各自使用 Acquire 相同；同一个 owner 重复获取时重入。
+// ReentrantLocker 本身不是 Locker，需要 Locker 时使用 Owner 返回的以固定持有者获取锁的 ReentrantOwner。
+type ReentrantLocker struct {
+	mu    sync.Mutex
+	holds map[reentrantKey]*reentrantHold
+}
+
+// reentrantKey 区分不同持有者持有的同名锁。
+type reentrantKey struct {
+	owner any
+	name  string
+}
+
+// reentrantHold 记录一个被持有的跨进程锁及其重入次数。
+type reentrantHold struct {
+	r     *Releaser
+	count int
+	// pending 不为 nil 表明正在获取跨进程锁，获取结束后被关闭。
+	pending chan struct{}
+}
+
+// NewReentrantLocker 创建 ReentrantLocker。
+func NewReentrantLocker() *ReentrantLocker {
+	return &ReentrantLocker{holds: make(map[reentrantKey]*reentrantHold)}
+}
+
+// AcquireFor 以 owner 作为持有者获取名为 name 的锁。owner 已经持有时只增加重入次数，其他持有者持有时一直等待。
+// owner 必须是可比较的值，与 map 的键相同，通常使用指向一个请求或任务的指针。
+// 每次成功调用返回的 Releaser 都必须且只能被释放一次。
+func (l *ReentrantLocker) AcquireFor(owner any, name string) (*Releaser, error) {
+	return l.acquire(reentrantKey{owner, name}, func() (*Releaser, error) { return Acquire(name) })
+}
+
+// AcquireForWithTimeout 与 AcquireFor 相同，但指定最长等待时间。owner 已经持有时立即返回。
+func (l *ReentrantLocker) AcquireForWithTimeout(owner any, name string, timeout time.Duration) (*Releaser, error) {
+	return l.acquire(reentrantKey{owner, name}, func() (*Releaser, error) { return AcquireWithTimeout(name, timeout) })
+}
+
+// IsHeldBy 表明 owner 当前是否通过 l 持有名为 name 的锁。
+func (l *ReentrantLocker) IsHeldBy(owner any, name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holds[reentrantKey{owner, name}] != nil
+}
+
+// ReentrantOwner 是以一个固定的持有者通过 ReentrantLocker 获取锁的 Locker。
+// 与 ReentrantLocker 一样，它只应在这个持有者中使用，不同的持有者应各自调用 Owner。
+type ReentrantOwner struct {
+	l     *ReentrantLocker
+	owner any
+}
+
+var _ Locker = (*ReentrantOwner)(nil)
+
+// Owner 返回以 owner 作为持有者的 ReentrantOwner。owner 的要求与 AcquireFor 相同。
+func (l *ReentrantLocker) Owner(owner any) *ReentrantOwner {
+	return &ReentrantOwner{l: l, owner: owner}
+}
+
+// Acquire 与 ReentrantLocker 的 AcquireFor 相同。
+func (o *ReentrantOwner) Acquire(name string) (*Releaser, error) {
+	return o.l.AcquireFor(o.owner, name)
+}
+
+// AcquireWithTimeout 与 ReentrantLocker 的 AcquireForWithTimeout 相同。
+func (o *ReentrantOwner) AcquireWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	return o.l.AcquireForWithTimeout(o.owner, name, timeout)
+}
+
+// IsHeld 表明这个持有者当前是否持有名为 name 的锁。
+func (o *ReentrantOwner) IsHeld(name string) bool {
+	return o.l.IsHeldBy(o.owner, name)
+}
+
+// acquire 以 key 中的持有者获取锁，需要获取跨进程锁时调用 f。
+// 同一个持有者的多次获取可以并发进行，只有一次会去获取跨进程锁，其余的等待它的结果。
+func (l *ReentrantLocker) acquire(key reentrantKey, f func() (*Releaser, error)) (*Releaser, error) {
+	l.mu.Lock()
+	for {
+		h := l.holds[key]
+		if h == nil {
+			break
+		}
+		if h.pending == nil {
+			h.count++
+			l.mu.Unlock()
+			return l.newReleaser(key, h), nil
+		}
+		// 其他协程正在获取同名的跨进程锁，等待它的结果。
+		pending := h.pending
+		l.mu.Unlock()
+		<-pending
+		l.mu.Lock()
+	}
+
+	// 获取跨进程锁时不持有 l.mu，以免阻塞对其他锁的释放。
+	h := &reentrantHold{pending: make(chan struct{})}
+	l.holds[key] = h
+	l.mu.Unlock()
+
+	r, err := f()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(h.pending)
+	h.pending = nil
+	if err != nil {
+		delete(l.holds, key)
+		return nil, err
+	}
+	h.r = r
+	h.count = 1
+	return l.newReleaser(key, h), nil
+}
+
+// newReleaser 返回代表一次重入的 Releaser。
+func (l *ReentrantLocker) newReleaser(key reentrantKey, h *reentrantHold) *Releaser {
+	r := NewReleaser(h.r.isAbandoned, func() error { return l.release(key, h) })
+	r.name = key.name
+	r.created = h.r.created
+	return r
+}
+
+func (l *ReentrantLocker) release(key reentrantKey, h *reentrantHold) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h.count--
+	if h.count > 0 {
+		return nil
+	}
+	delete(l.holds, key)
+	return h.r.Release()
+}
@@ -0,0 +1,107 @@
+package mutex
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReentrantLocker(t *testing.T) {
+	name := testName("reentrant_locker")
+	l := NewReentrantLocker().Owner(new(int))
+
+	outer, err := l.Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := l.AcquireWithTimeout(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inner.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsHeld(name) {
+		t.Fatal("expect held after inner release")
+	}
+	if r, ok, _ := TryAcquire(name); ok {
+		_ = r.Release()
+		t.Fatal("expect lock still held by outer")
+	}
+
+	if err := outer.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if l.IsHeld(name) {
+		t.Fatal("expect not held after outer release")
+	}
+	r, ok, err := TryAcquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expect lock free")
+	}
+	_ = r.Release()
+}
+
+func TestReentrantLockerOwners(t *testing.T) {
+	name := testName("reentrant_locker_owners")
+	l := NewReentrantLocker()
+	a, b := new(int), new(int)
+
+	outer, err := l.AcquireFor(a, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := l.AcquireForWithTimeout(a, name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不同的持有者之间互斥。
+	if r, err := l.AcquireForWithTimeout(b, name, 20*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if !l.IsHeldBy(a, name) || l.IsHeldBy(b, name) || !l.Owner(a).IsHeld(name) {
+		t.Fatal("expect held by a only")
+	}
+
+	acquired := make(chan *Releaser)
+	go func() {
+		r, err := l.AcquireFor(b, name)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- r
+	}()
+	if err := inner.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.Release(); err != nil {
+		t.Fatal(err)
+	}
+	r := <-acquired
+	if r == nil {
+		t.FailNow()
+	}
+	if !l.IsHeldBy(b, name) {
+		t.Fatal("expect held by b")
+	}
+
+	// 作为 Locker 使用时，不同持有者的 ReentrantOwner 之间同样互斥。
+	var locker Locker = l.Owner(a)
+	if r, err := locker.AcquireWithTimeout(name, 20*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		if err == nil {
+			_ = r.Release()
+		}
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+package mutex
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// registry 登记了当前进程通过本包获得、且尚未释放的全部锁，供 ReleaseAll 使用。
+// 它只引用 lease，释放时即被移除，因此既不会保留已释放的锁，也不会妨碍泄漏检测。
+var registry struct {
+	mu     sync.Mutex
+	leases map[*lease]struct{}
+}
+
+// openHandles 是本包为等待中与持有中的锁打开的句柄数量，unix 下为锁文件的文件描述符数量。
+var openHandles atomic.Int64
+
+// OpenHandleCount 返回当前进程中本包为等待中与持有中的锁打开的句柄数量，unix 下为锁文件的文件描述符数量。
+// 它用于诊断句柄泄漏：数量持续增长而实际持有的锁并没有增多，通常说明有 Releaser 没有被释放。
+// IsHeld、WaitersFor 等只短暂打开句柄的探测，以及伴生的信号量、共享内存与 NamedEvent 不计入统计。
+// 计数只反映当前进程，与其他进程无关。
+func OpenHandleCount() int {
+	return int(openHandles.Load())
+}
+
+// LiveLocks 返回当前进程通过本包获得、且尚未释放的锁的名字，按字典序排列，同名的锁被持有多次时出现多次。
+// 它与 ReleaseAll 使用同一份登记表，NewReleaser 构造的 Releaser 不在其中。
+// 结果只是调用时当前进程的快照，用于观察，不能据此判断锁是否可以获得。
+func LiveLocks() []string {
+	registry.mu.Lock()
+	names := make([]string, 0, len(registry.leases))
+	for l := range registry.leases {
+		names = append(names, l.name)
+	}
+	registry.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// register 登记 l。
+func register(l *lease) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.leases == nil {
+		registry.leases = make(map[*lease]struct{})
+	}
+	registry.leases[l] = struct{}{}
+}
+
+// deregister 移除 l 的登记。
+func deregister(l *lease) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.leases, l)
+}
+
+// ReleaseAll 释放当前进程通过本包获得、且尚未释放的全部锁，返回释放过程中遇到的错误，全部成功时返回 nil。
+// 它是在 panic 恢复或信号处理等退出路径上的兜底手段，正常的代码依然应该逐个释放自己获得的锁。
+// 被 ReleaseAll 释放的 Releaser 再调用 Release 会返回 ErrAlreadyReleased。
+// NewReleaser 构造的 Releaser 不由本包获得，不会被释放。
+func ReleaseAll() []error {
+	registry.mu.Lock()
+	leases := make([]*lease, 0, len(registry.leases))
+	for l := range registry.leases {
+		leases = append(leases, l)
+	}
+	registry.mu.Unlock()
+
+	var errs []error
+	for _, l := range leases {
+		// 与并发的 Release 竞争失败的锁已经被释放了。
+		if err := l.releaseUntil(nil, nil); err != nil && !errors.Is(err, ErrAlreadyReleased) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Purge 释放当前进程持有的名为 names 的锁，并清除这些锁留在系统中的状态，
+// 使之后获得它们时不会看到之前的运行遗留的遗弃状态或等待者计数。返回清理过程中遇到的全部错误。
+//
+// 它用于测试之间的清理，例如在 TestMain 中调用，锁仍可能被其他进程使用时不要调用它，否则会破坏互斥。
+// windows 下无法关闭其他进程持有的句柄，内核对象在所有句柄关闭后由系统销毁，因此只释放当前进程持有的锁；
+// unix 下还会删除锁文件、等待者计数文件与 AcquireLease 的租约文件。
+func Purge(names ...string) error {
+	encoded := make([]string, len(names))
+	set := make(map[string]bool, len(names))
+	for i, name := range names {
+		encoded[i] = encodeName(name)
+		set[encoded[i]] = true
+	}
+	registry.mu.Lock()
+	var leases []*lease
+	for l := range registry.leases {
+		if set[l.name] {
+			leases = append(leases, l)
+		}
+	}
+	registry.mu.Unlock()
+
+	var errs []error
+	for _, l := range leases {
+		if err := l.releaseUntil(nil, nil); err != nil && !errors.Is(err, ErrAlreadyReleased) {
+			errs = append(errs, nameError(l.name, err))
+		}
+	}
+	for _, name := range encoded {
+		if err := validateName(name); err != nil {
+			errs = append(errs, nameError(name, err))
+			continue
+		}
+		if err := osPurge(name); err != nil {
+			errs = append(errs, nameError(name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
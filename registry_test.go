@@ -0,0 +1,78 @@
+package mutex
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestLiveLocks(t *testing.T) {
+	a, b := testName("live_locks_a"), testName("live_locks_b")
+	base := OpenHandleCount()
+
+	ra, err := Acquire(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, err := Acquire(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := OpenHandleCount(); n != base+2 {
+		t.Fatalf("expect %d handles, got %d", base+2, n)
+	}
+	live := LiveLocks()
+	for _, name := range []string{a, b} {
+		if i := sort.SearchStrings(live, name); i == len(live) || live[i] != name {
+			t.Fatalf("expect %q in %v", name, live)
+		}
+	}
+
+	_ = ra.Release()
+	_ = rb.Release()
+	if n := OpenHandleCount(); n != base {
+		t.Fatalf("expect %d handles, got %d", base, n)
+	}
+	for _, name := range LiveLocks() {
+		if name == a || name == b {
+			t.Fatalf("expect %q to be released", name)
+		}
+	}
+}
+
+func TestReleaseAll(t *testing.T) {
+	names := []string{testName("release_all_a"), testName("release_all_b")}
+
+	var rs []*Releaser
+	for _, name := range names {
+		r, err := Acquire(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, r)
+	}
+
+	if errs := ReleaseAll(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	for _, r := range rs {
+		if err := r.Release(); !errors.Is(err, ErrAlreadyReleased) {
+			t.Fatalf("expect ErrAlreadyReleased, got %v", err)
+		}
+	}
+	for _, name := range names {
+		r, err := AcquireWithTimeout(name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Release()
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, r := range rs {
+		if _, ok := registry.leases[r.lease]; ok {
+			t.Fatal("expect released leases to be removed")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package mutex
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// FromInheritedHandle 在子进程中等待从父进程继承的互斥量句柄 h，获得锁后返回负责释放它的 Releaser，见 WithInheritHandle。
+// 互斥量属于获得它的线程，继承句柄并不会继承锁，因此它会一直等到父进程（或其他持有者）释放锁。
+// 返回的 Releaser 拥有 h：Release 释放锁并关闭 h，获取失败时 h 同样被关闭。
+// h 不是有效的互斥量句柄时返回包装了 windows.ERROR_INVALID_HANDLE 等原因的错误。
+// 返回的 Releaser 没有锁名，不计入 Stats 与 WaitersFor，也不会被 ReleaseAll 释放。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func FromInheritedHandle(h uintptr) (*Releaser, error) {
+	obj := object{
+		create: func(*uint16) (windows.Handle, error) {
+			return windows.Handle(h), windows.ERROR_ALREADY_EXISTS
+		},
+		release: releaseMutex,
+		mutant:  true,
+	}
+	r, err := acquireObject(obj, "", waitForever, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.acquiredAt = time.Now()
+	return r.pin(), nil
+}
+
+// Handle 返回锁对应的内核对象句柄，用于与其他 windows api 互操作，例如传给 C 组件或 WaitForMultipleObjects。
+// 句柄由 Releaser 所有，只在 Release 之前有效。调用者不能关闭它，也不能通过它释放锁（ReleaseMutex）。
+// 注意互斥量属于获得它的线程，在其他线程上等待该句柄会尝试获得同一个互斥量，因此会一直等到锁被释放。
+// 不是由本包获得的 Releaser（例如 NewReleaser 构造的）与已经释放的 Releaser 返回 0。
+func (r *Releaser) Handle() windows.Handle {
+	if r.released.Load() {
+		return 0
+	}
+	return windows.Handle(r.handle)
+}
@@ -0,0 +1,50 @@
+package mutex
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// AcquireWithRetry 创建跨进程互斥锁，等待超时后按指数退避重试，最多尝试 attempts 次。
+// 第 i 次尝试的最长等待时间约为 initialBackoff * 2^(i-1)，并带有 ±50% 的随机抖动，
+// 避免多个进程同步地重试。等待本身就是退避，两次尝试之间不会额外休眠。
+// 只有 ErrWaitTimeout 会触发重试，其他错误立即返回；全部尝试都超时后返回最后一次的错误。
+// attempts 小于 1 时视为 1。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithRetry(name string, attempts int, initialBackoff time.Duration) (*Releaser, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	backoff := initialBackoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		var r *Releaser
+		r, err = AcquireWithTimeout(name, jitter(backoff))
+		if !errors.Is(err, ErrWaitTimeout) {
+			return r, err
+		}
+		if backoff < max_WAIT_MILLISECONDS/4 {
+			backoff *= 2
+		}
+	}
+	return nil, err
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))
+)
+
+// jitter 返回 [d/2, d*3/2) 区间内的随机时长。
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return d/2 + time.Duration(jitterRand.Int63n(int64(d)))
+}
@@ -0,0 +1,31 @@
+package mutex
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireWithRetry(t *testing.T) {
+	name := testName("acquire_with_retry")
+
+	r1, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AcquireWithRetry(name, 3, 10*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expect ErrWaitTimeout, got %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { _ = r1.Release() })
+	r2, err := AcquireWithRetry(name, 10, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r2.Release()
+
+	if _, err := AcquireWithRetry("", 3, time.Millisecond); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package mutex
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrNotWriteLock 表明对不是读写锁的写锁的 Releaser 调用了 DowngradeToRead。
+var ErrNotWriteLock = errors.New("mutex downgrade: not a write lock")
+
+// maxReaders 是读写锁允许同时持有读锁的最大持有者数。
+const maxReaders = 128
+
+// 读写锁由一个具名互斥量（门）和一个最大计数为 maxReaders 的具名信号量组成，它们的名字由 name 加后缀得到。
+// 读者通过门后占用信号量的一个计数；写者通过门后占用信号量的全部计数，因此会等待所有读者离开。
+// 写者在收集计数期间一直持有门，后来的读者会在门外等待，所以写者不会被源源不断的读者饿死；
+// 反过来，持续到来的写者可能使读者长时间等待。
+//
+// 信号量没有所有者：持有者在没有释放时就退出，它占用的计数不会被归还，操作系统也不会像互斥量那样报告遗弃。
+// 读者崩溃后少了一个计数，之后的写者永远无法收集到全部计数；写者在持有写锁或收集计数期间崩溃后，
+// 丢失的计数最多为 maxReaders 个，之后的读者与写者都可能永远无法获得锁。
+// 这种状态一直持续到所有进程都关闭了信号量的句柄、信号量被销毁为止。
+const (
+	rwGateSuffix    = "#rw.gate"
+	rwReadersSuffix = "#rw.readers"
+)
+
+// AcquireRead 获取跨进程读写锁的读锁。多个持有者可以同时持有读锁，但读锁与写锁互斥。
+// 同时持有读锁的持有者最多为 128 个。IsAbandoned 始终返回 false。
+// 读者在没有释放时就退出会永久占用一个计数，此后所有 AcquireWrite 都会一直等待（AcquireWriteWithTimeout 则总是超时），
+// 直到所有进程都关闭了读写锁为止。
+// 返回 Releaser 的 Release 方法用于释放读锁。它必须且只能被调用一次。
+func AcquireRead(name string) (*Releaser, error) {
+	return acquireRW(name, 1, waitForever)
+}
+
+// AcquireReadWithTimeout 获取跨进程读写锁的读锁，并指定最长等待时间。
+// 返回 Releaser 的 Release 方法用于释放读锁。它必须且只能被调用一次。
+func AcquireReadWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquireRW(name, 1, timeout)
+}
+
+// AcquireWrite 获取跨进程读写锁的写锁。写锁与其他所有读锁和写锁互斥，会等待所有读者释放后才返回。
+//
+// 注意读写锁无法从持有者崩溃中恢复：任何一个读者或写者在没有释放时就退出后，它占用的计数永远不会被归还，
+// AcquireWrite 会永远等待下去，写者崩溃时读者同样如此，直到所有进程都关闭了读写锁为止。
+// 持有者可能崩溃时应使用 AcquireWriteWithTimeout，并把超时当作需要人工介入的信号。
+//
+// 返回 Releaser 的 Release 方法用于释放写锁。它必须且只能被调用一次。
+func AcquireWrite(name string) (*Releaser, error) {
+	return acquireRW(name, maxReaders, waitForever)
+}
+
+// AcquireWriteWithTimeout 获取跨进程读写锁的写锁，并指定最长等待时间。
+// 返回 Releaser 的 Release 方法用于释放写锁。它必须且只能被调用一次。
+func AcquireWriteWithTimeout(name string, timeout time.Duration) (*Releaser, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	return acquireRW(name, maxReaders, timeout)
+}
+
+// acquireRW 通过门后从读者信号量中占用 units 个计数。
+func acquireRW(name string, units int32, timeout time.Duration) (*Releaser, error) {
+	name = encodeName(name)
+	if err := validateName(name + rwReadersSuffix); err != nil {
+		return nil, err
+	}
+
+	f := func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		var deadline time.Time
+		if timeout != waitForever {
+			deadline = time.Now().Add(timeout)
+		}
+
+		gate, err := acquireObject(mutexObject, name+rwGateSuffix, timeout, done)
+		if err != nil {
+			return nil, err
+		}
+		defer gate.Release()
+
+		sem, err := createSemaphore(nil, maxReaders, maxReaders, windows.StringToUTF16Ptr(name+rwReadersSuffix))
+		if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+			return nil, createFailed(name, err)
+		}
+
+		for taken := int32(0); taken < units; taken++ {
+			rt, err := waitDeadline(sem, deadline)
+			switch rt {
+			case windows.WAIT_OBJECT_0:
+			case uint32(windows.WAIT_TIMEOUT):
+				err = errWaitTimeout
+			case windows.WAIT_FAILED:
+				err = waitFailed(err)
+			default:
+				err = unexpectedWait(rt)
+			}
+			if err != nil {
+				if taken > 0 {
+					_ = releaseSemaphore(sem, taken, nil)
+				}
+				windows.CloseHandle(sem)
+				return nil, err
+			}
+		}
+
+		release := func() error {
+			err := releaseSemaphore(sem, units, nil)
+			windows.CloseHandle(sem)
+			return err
+		}
+		l := &lease{release: release}
+		if units == maxReaders {
+			l.downgrade = func() (func() error, error) {
+				// 归还除一个以外的全部计数，信号量的计数一次性增加，这期间一直占用着一个计数，写者无法获得全部计数。
+				if err := releaseSemaphore(sem, maxReaders-1, nil); err != nil {
+					return nil, err
+				}
+				return func() error {
+					err := releaseSemaphore(sem, 1, nil)
+					windows.CloseHandle(sem)
+					return err
+				}, nil
+			}
+		}
+		return &Releaser{lease: l}, nil
+	}
+	return acquireWith(f, name, timeout, nil)
+}
+
+// DowngradeToRead 将 AcquireWrite 或 AcquireWriteWithTimeout 获得的写锁原地转换为读锁，并返回持有读锁的新 Releaser。
+// 转换是原子的：转换期间读锁一直被占用，其他写者无法在写锁与读锁之间获得锁，会一直等到新 Releaser 释放读锁后才能获得写锁；
+// 其他读者则可以在转换后立即获得读锁。
+// 转换成功后原 Releaser 的 Release 不再释放锁，而是返回 ErrTransferred；新 Releaser 的 Release 必须且只能被调用一次。
+// 对不是写锁的 Releaser 调用时返回 ErrNotWriteLock，对已经释放或转交过的 Releaser 调用时返回 ErrAlreadyReleased 或 ErrTransferred，
+// 转换失败时依然持有原来的写锁。DowngradeToRead 不能与同一个 Releaser 的 Release 并发调用。
+func (r *Releaser) DowngradeToRead() (*Releaser, error) {
+	r.checkCopy()
+	if r.released.Load() {
+		return nil, nameError(r.name, r.releasedError())
+	}
+	if r.downgrade == nil {
+		return nil, nameError(r.name, ErrNotWriteLock)
+	}
+	if !r.released.CompareAndSwap(false, true) {
+		return nil, nameError(r.name, r.releasedError())
+	}
+	release, err := r.downgrade()
+	if err != nil {
+		r.released.Store(false)
+		return nil, nameError(r.name, err)
+	}
+	r.transferred.Store(true)
+
+	l := &lease{
+		name:       r.name,
+		acquiredAt: r.acquiredAt,
+		tracked:    r.tracked,
+		release:    release,
+	}
+	t := &Releaser{lease: l}
+	t.pin()
+	if r.tracked {
+		deregister(r.lease)
+		register(l)
+		trackLeak(t)
+	}
+	return t, nil
+}
+
+// waitDeadline 等待 h 直到 deadline。deadline 为零值时表示无限等待。
+// 单次等待的时间有上限，剩余时间更长时会重复等待，直到等满 deadline 才返回 WAIT_TIMEOUT。
+func waitDeadline(h windows.Handle, deadline time.Time) (uint32, error) {
+	for {
+		rt, err := windows.WaitForSingleObject(h, remainingMilliseconds(deadline))
+		if rt != uint32(windows.WAIT_TIMEOUT) || !time.Now().Before(deadline) {
+			return rt, err
+		}
+	}
+}
+
+// remainingMilliseconds 返回距离 deadline 剩余的毫秒数，超过单次等待的上限时返回上限。deadline 为零值时表示无限等待。
+func remainingMilliseconds(deadline time.Time) uint32 {
+	if deadline.IsZero() {
+		return windows.INFINITE
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return 0
+	}
+	if ms := d.Milliseconds(); ms < windows.INFINITE {
+		return uint32(ms)
+	}
+	return windows.INFINITE - 1
+}
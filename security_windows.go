@@ -0,0 +1,44 @@
+package mutex
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrInvalidSecurityDescriptor 表明传入的 SDDL 字符串无法被解析为安全描述符。
+var ErrInvalidSecurityDescriptor = errors.New("mutex acquire: invalid security descriptor")
+
+// AcquireWithSecurity 使用 SDDL 字符串描述的安全描述符创建跨进程互斥锁，
+// 例如 "D:(A;;GA;;;AU)" 允许所有已认证用户打开并等待它。
+// 注意 CreateMutex 打开已存在的互斥量时请求的是 MUTEX_ALL_ACCESS，因此需要共享锁的账户应被授予完整权限。
+// 安全描述符只在互斥量第一次被创建时生效，打开已存在的互斥量时沿用其原有的安全描述符。
+// SDDL 解析失败时返回的错误包装了 ErrInvalidSecurityDescriptor。
+// 返回 Releaser 的 Release 方法用于释放锁资源。它必须且只能被调用一次。
+func AcquireWithSecurity(name, sddl string) (*Releaser, error) {
+	sa, err := securityAttributes(sddl)
+	if err != nil {
+		return nil, err
+	}
+	obj := newMutexObject(sa)
+	f := func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		return acquireMutex(obj, sa, name, timeout, done)
+	}
+	return acquireWith(f, encodeName(name), waitForever, nil)
+}
+
+// securityAttributes 将 SDDL 字符串转换为 SECURITY_ATTRIBUTES。
+// https://learn.microsoft.com/zh-cn/windows/win32/secauthz/security-descriptor-string-format
+func securityAttributes(sddl string) (*windows.SecurityAttributes, error) {
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidSecurityDescriptor, sddl, err)
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}, nil
+}
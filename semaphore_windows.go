@@ -0,0 +1,80 @@
+package mutex
+
+import (
+	"errors"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ErrInvalidSemaphoreMax 表明信号量的最大计数小于 1。
+var ErrInvalidSemaphoreMax = errors.New("mutex acquire: semaphore max must be at least 1")
+
+var (
+	modkernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procCreateSemaphoreW = modkernel32.NewProc("CreateSemaphoreW")
+	procReleaseSemaphore = modkernel32.NewProc("ReleaseSemaphore")
+)
+
+// semaphoreSuffix 是信号量的内核对象名的后缀，使同名的互斥锁与信号量成为两个独立的对象，而不是相互冲突。
+const semaphoreSuffix = "#semaphore"
+
+// AcquireSemaphore 创建跨进程计数信号量，并占用其中一个计数。最多允许 max 个持有者同时持有它。
+// 实际的内核对象名为 name + "#semaphore"，因此它与同名的互斥锁互不影响；与其他程序共享信号量时应使用这个名字。
+// max 只在信号量第一次被创建时生效，之后打开同名信号量时沿用已有的最大计数。max 小于 1 时返回 ErrInvalidSemaphoreMax。
+// 信号量没有所有者的概念，持有者在没有释放时就退出会永久占用一个计数，IsAbandoned 始终返回 false。
+// 返回 Releaser 的 Release 方法用于归还计数。它必须且只能被调用一次。
+func AcquireSemaphore(name string, max int32) (*Releaser, error) {
+	if max < 1 {
+		return nil, ErrInvalidSemaphoreMax
+	}
+	name = encodeName(name)
+	if err := validateName(name + semaphoreSuffix); err != nil {
+		return nil, err
+	}
+	f := func(name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+		return acquireObject(semaphoreObject(max), name+semaphoreSuffix, timeout, done)
+	}
+	return acquireWith(f, name, waitForever, nil)
+}
+
+// semaphoreObject 是初始计数与最大计数均为 max 的具名信号量。
+func semaphoreObject(max int32) object {
+	return object{
+		create: func(name *uint16) (windows.Handle, error) {
+			return createSemaphore(nil, max, max, name)
+		},
+		release: func(h windows.Handle) error {
+			return releaseSemaphore(h, 1, nil)
+		},
+	}
+}
+
+// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createsemaphorew
+func createSemaphore(sa *windows.SecurityAttributes, initialCount, maximumCount int32, name *uint16) (windows.Handle, error) {
+	r, _, err := procCreateSemaphoreW.Call(
+		uintptr(unsafe.Pointer(sa)),
+		uintptr(initialCount),
+		uintptr(maximumCount),
+		uintptr(unsafe.Pointer(name)),
+	)
+	h := windows.Handle(r)
+	if h == 0 || errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+		return h, err
+	}
+	return h, nil
+}
+
+// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-releasesemaphore
+func releaseSemaphore(h windows.Handle, releaseCount int32, previousCount *int32) error {
+	r, _, err := procReleaseSemaphore.Call(
+		uintptr(h),
+		uintptr(releaseCount),
+		uintptr(unsafe.Pointer(previousCount)),
+	)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package mutex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	name := testName("stats")
+	before := Stats()
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireWithTimeout(name, 10*time.Millisecond); err == nil {
+		t.Fatal("expect error")
+	}
+
+	s := Stats()
+	if n := s.Holders[name]; n != 1 {
+		t.Fatalf("expect 1 holder, got %d", n)
+	}
+	if s.Acquisitions-before.Acquisitions < 1 {
+		t.Fatal("expect acquisitions counted")
+	}
+	if s.Timeouts-before.Timeouts < 1 {
+		t.Fatal("expect timeouts counted")
+	}
+
+	_ = r.Release()
+	if n, ok := Stats().Holders[name]; ok {
+		t.Fatalf("expect no holder, got %d", n)
+	}
+}
@@ -0,0 +1,8 @@
+// copylock 复制了 mutex.Releaser，用于验证 go vet 能够报告这种复制，见 TestReleaserNoCopy。
+package copylock
+
+import "github.com/kvii/mutex"
+
+func copyReleaser(r *mutex.Releaser) mutex.Releaser {
+	return *r
+}
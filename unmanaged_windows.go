@@ -0,0 +1,87 @@
+package mutex
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// UnmanagedReleaser 用于释放 AcquireUnmanaged 获得的锁。它的方法只能在获得锁的线程上调用，不能并发使用。
+type UnmanagedReleaser struct {
+	name        string
+	h           windows.Handle
+	isAbandoned bool
+	created     bool
+	released    bool
+}
+
+// AcquireUnmanaged 在当前线程上直接创建并等待跨进程互斥锁，不经过本包的 worker 线程。
+// 它适合已经在专用的、调用过 runtime.LockOSThread 的协程上加锁的调用者，省去额外的线程与线程之间的切换。
+//
+// 调用者必须遵守严格的约定：调用 AcquireUnmanaged 之前当前协程已经锁定在操作系统线程上，
+// 并且在同一个协程上、解除锁定之前调用 Release。互斥量属于获得它的线程，
+// 在其他线程上调用 Release 会失败并返回包装了 ErrNotOwner 的错误；
+// 线程在没有释放锁时就退出，锁会被遗弃。
+//
+// 锁一直等待到获得为止。得到的锁不经过进程内的锁排队，不计入 Stats 与 WaitersFor，不通知观察者，也不会被 ReleaseAll 释放。
+// 同一个线程再次获取已经持有的互斥量会立即成功（互斥量对持有它的线程是可重入的），不会与自己互斥。
+func AcquireUnmanaged(name string) (*UnmanagedReleaser, error) {
+	name = encodeName(name)
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createmutexw
+	h, err := windows.CreateMutex(nil, false, windows.StringToUTF16Ptr(name))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return nil, nameError(name, createFailed(name, err))
+	}
+	created := err == nil
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-waitforsingleobject
+	rt, err := windows.WaitForSingleObject(h, windows.INFINITE)
+	switch rt {
+	case windows.WAIT_OBJECT_0, windows.WAIT_ABANDONED:
+		return &UnmanagedReleaser{name: name, h: h, isAbandoned: rt == windows.WAIT_ABANDONED, created: created}, nil
+	case windows.WAIT_FAILED:
+		err = waitFailed(err)
+	default:
+		err = unexpectedWait(rt)
+	}
+	windows.CloseHandle(h)
+	return nil, nameError(name, err)
+}
+
+// Name 返回获取锁时使用的锁名。
+func (r *UnmanagedReleaser) Name() string {
+	return r.name
+}
+
+// IsAbandoned 与 Releaser.IsAbandoned 相同。
+func (r *UnmanagedReleaser) IsAbandoned() bool {
+	return r.isAbandoned
+}
+
+// WasCreated 与 Releaser.WasCreated 相同。
+func (r *UnmanagedReleaser) WasCreated() bool {
+	return r.created
+}
+
+// Handle 返回互斥量的句柄。句柄只在 Release 之前有效，调用者不能关闭它。
+func (r *UnmanagedReleaser) Handle() windows.Handle {
+	return r.h
+}
+
+// Release 释放锁并关闭句柄，它必须在获得锁的线程上调用。重复调用返回 ErrAlreadyReleased。
+// ReleaseMutex 失败时（例如在其他线程上调用）句柄不会被关闭，调用者可以在正确的线程上再次调用 Release。
+func (r *UnmanagedReleaser) Release() error {
+	if r.released {
+		return ErrAlreadyReleased
+	}
+	if err := releaseMutex(r.h); err != nil {
+		return nameError(r.name, err)
+	}
+	r.released = true
+	return windows.CloseHandle(r.h)
+}
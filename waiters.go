@@ -0,0 +1,22 @@
+package mutex
+
+// waitersSuffix 是记录等待者数量的伴生对象的名字后缀。
+const waitersSuffix = "#waiters"
+
+// WaitersFor 返回正在等待名为 name 的锁的使用者数量，用于观察锁的竞争情况。
+// 只有无法立即获得锁而进入等待的使用者才会被统计，它们在开始等待时将计数加一，结束等待时减一，不会影响加锁本身。
+// 同一进程内同名的等待者无论是在进程内的锁上排队，还是在等待操作系统的锁，都只被统计一次。
+// 统计是尽力而为的近似值：读取计数的同时等待者可能正在进出；等待者异常退出时没有机会减一，计数会偏大，
+// windows 下伴生的信号量在所有句柄关闭后被销毁，计数随之归零，unix 下计数文件一直保留，偏差会持续存在。
+// 读写锁中对信号量的等待不计入统计。AcquireAny 的等待计入它等待的每个名字。
+func WaitersFor(name string) (int, error) {
+	name = encodeName(name)
+	if err := validateName(name); err != nil {
+		return 0, err
+	}
+	n, err := osWaitersFor(name)
+	if err != nil {
+		return 0, nameError(name, err)
+	}
+	return n, nil
+}
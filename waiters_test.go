@@ -0,0 +1,52 @@
+package mutex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitersFor(t *testing.T) {
+	name := testName("waiters_for")
+
+	r, err := Acquire(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		r, err := AcquireWithTimeout(name, 5*time.Second)
+		if err == nil {
+			err = r.Release()
+		}
+		done <- err
+	}()
+
+	waitWaiters(t, name, 1)
+	if err := r.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	waitWaiters(t, name, 0)
+}
+
+// waitWaiters 等待 WaitersFor(name) 变为 n，超过 5 秒时测试失败。
+func waitWaiters(t *testing.T, name string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := WaitersFor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d waiters, got %d", n, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package mutex
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// 等待者的数量记录在名为 name + waitersSuffix 的计数文件中，文件的格式与锁文件中的持有者记录相同，
+// 读写时在计数文件上加 flock 锁。
+
+// enterWaiting 将 name 的等待者数量加一，返回的函数将其减一。记录失败时统计被忽略。
+func enterWaiting(name string) (leave func()) {
+	path := lockPath(name + waitersSuffix)
+	_ = addWaiters(path, 1)
+	return func() { _ = addWaiters(path, -1) }
+}
+
+// addWaiters 将计数文件 path 中的计数加上 delta。
+func addWaiters(path string, delta int) error {
+	fd, _, err := openLockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if err := flock(fd, unix.LOCK_EX); err != nil {
+		return err
+	}
+
+	n, err := readOwner(fd)
+	if err != nil {
+		return err
+	}
+	n += delta
+	if n < 0 {
+		n = 0
+	}
+	return writeOwner(fd, n)
+}
+
+// osWaitersFor 读取计数文件中的计数。文件不存在时说明从没有人等待过。
+func osWaitersFor(name string) (int, error) {
+	fd, err := unix.Open(lockPath(name+waitersSuffix), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	if err := flock(fd, unix.LOCK_SH); err != nil {
+		return 0, err
+	}
+
+	n, err := readOwner(fd)
+	if err != nil || n < 0 {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,41 @@
+package mutex
+
+import (
+	"math"
+
+	"golang.org/x/sys/windows"
+)
+
+// 等待者的数量记录在名为 name + waitersSuffix 的具名信号量的计数中。
+
+// enterWaiting 将 name 的等待者数量加一，返回的函数将其减一。记录失败时统计被忽略。
+func enterWaiting(name string) (leave func()) {
+	h, _ := createSemaphore(nil, 0, math.MaxInt32, windows.StringToUTF16Ptr(name+waitersSuffix))
+	if h == 0 {
+		return func() {}
+	}
+	if err := releaseSemaphore(h, 1, nil); err != nil {
+		windows.CloseHandle(h)
+		return func() {}
+	}
+	return func() {
+		_, _ = windows.WaitForSingleObject(h, 0)
+		windows.CloseHandle(h)
+	}
+}
+
+// osWaitersFor 读取信号量的计数。windows 没有直接读取计数的 api，这里先将计数加一得到原来的值，再将其减回去。
+func osWaitersFor(name string) (int, error) {
+	h, err := createSemaphore(nil, 0, math.MaxInt32, windows.StringToUTF16Ptr(name+waitersSuffix))
+	if h == 0 {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var prev int32
+	if err := releaseSemaphore(h, 1, &prev); err != nil {
+		return 0, err
+	}
+	_, _ = windows.WaitForSingleObject(h, 0)
+	return int(prev), nil
+}
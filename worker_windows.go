@@ -0,0 +1,600 @@
+package mutex
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// windows 互斥量属于获得它的线程，必须在同一个线程上释放。为了不为每个被持有的锁都占用一个操作系统线程，
+// 所有内核对象都交给 worker 处理：每个 worker 是一个锁定在操作系统线程上的协程，
+// 它在自己的线程上创建、等待与释放多个内核对象，并通过 WaitForMultipleObjects 同时等待多个请求。
+//
+// 互斥量对持有它的线程是可重入的：同一个线程等待已经持有的互斥量会立即成功。
+// 因此一个 worker 上同一个对象最多只能有一个请求，指向同一个对象的并发请求会被分配到不同的 worker。
+// 请求以 objectKey 区分对象；objectKey 无法识别的别名（例如继承的句柄）在获得后由 ownedRecursively 发现，
+// 此时撤销这次重入，并把请求转交给其他 worker 重新等待。
+// 一个 worker 最多同时等待 maxWorkerWaits 个对象，已经获得的锁不占用这个名额。
+// 等待多个对象中任意一个的请求（AcquireAny）在一个 worker 上同时等待它的所有对象，获得其中一个后关闭其余的句柄。
+// worker 在没有任何等待中或持有中的请求时退出，其线程随之销毁。
+
+// maxWorkerWaits 是一个 worker 同时等待的最多对象数。WaitForMultipleObjects 最多等待 64 个对象，其中一个是唤醒事件。
+const maxWorkerWaits = maximumWaitObjects - 1
+
+// pool 管理所有的 worker。
+var pool struct {
+	mu      sync.Mutex
+	workers []*worker
+}
+
+// worker 是一个锁定在操作系统线程上的协程。
+type worker struct {
+	// wake 是自动重置事件，用于唤醒阻塞在 WaitForMultipleObjects 上的 worker。
+	wake windows.Handle
+
+	// mu 保护 queue 与 closed。
+	mu     sync.Mutex
+	queue  []func()
+	closed bool
+
+	// 以下字段由 pool.mu 保护。
+	names   map[string]int // 该 worker 上等待中或持有中的请求的 objectKey
+	waiting int            // 该 worker 上等待中的对象数
+
+	// 以下字段只在 worker 自己的协程中访问。
+	pending []*request
+}
+
+// request 是一次加锁请求。
+type request struct {
+	obj      object
+	name     string
+	deadline time.Time // 零值表示无限等待
+	reply    chan result
+	w        *worker   // 由 pool.mu 保护
+	canceled bool      // 由 pool.mu 保护，见 abort
+	avoid    []*worker // 已经持有该对象、不能再处理该请求的 worker，见 reassign
+	h        windows.Handle
+	created  bool
+	leave    func() // 结束等待者统计，见 enterWaiting
+	// alts 不为空时 req 等待其中任意一个对象，此时不使用 name、h 与 created。
+	// 获得其中一个后，其余的句柄被关闭，req 变为只持有该对象的普通请求，index 为它在 alts 中的下标，见 choose。
+	alts  []alt
+	index int
+	// blocked 不为 nil 时，在请求开始等待时被关闭，使发起请求的协程可以发出 PhaseBlocked 事件。只在设置了观察者时创建。
+	blocked chan struct{}
+}
+
+// alt 是 AcquireAny 等待的对象之一。
+type alt struct {
+	name    string
+	h       windows.Handle
+	created bool
+}
+
+// result 是加锁请求的结果。
+type result struct {
+	abandoned bool
+	created   bool
+	err       error
+}
+
+// submit 将 req 分配给一个 worker。没有合适的 worker 时创建一个新的。req 已经被 abort 时返回 ErrCanceled。
+func submit(req *request) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if req.canceled {
+		return ErrCanceled
+	}
+
+	names := req.names()
+	var w *worker
+	for _, c := range pool.workers {
+		if c.waiting+len(names) <= maxWorkerWaits && !c.knowsAny(names) && !req.avoids(c) {
+			w = c
+			break
+		}
+	}
+	if w == nil {
+		var err error
+		w, err = newWorker()
+		if err != nil {
+			return err
+		}
+		pool.workers = append(pool.workers, w)
+	}
+
+	for _, name := range names {
+		w.names[objectKey(name)]++
+	}
+	w.waiting += len(names)
+	req.w = w
+	w.push(func() { w.start(req) })
+	return nil
+}
+
+// abort 让当前处理 req 的 worker 放弃等待它。正在被 reassign 转交的 req 不会再被交给新的 worker。
+func (req *request) abort() {
+	pool.mu.Lock()
+	req.canceled = true
+	w := req.w
+	pool.mu.Unlock()
+	w.push(func() { w.cancel(req) })
+}
+
+// names 返回 req 等待的所有对象的名字。
+func (req *request) names() []string {
+	if len(req.alts) == 0 {
+		return []string{req.name}
+	}
+	names := make([]string, len(req.alts))
+	for i, a := range req.alts {
+		names[i] = a.name
+	}
+	return names
+}
+
+// handles 返回 req 等待的所有对象的句柄，顺序与 names 相同。
+func (req *request) handles() []windows.Handle {
+	if len(req.alts) == 0 {
+		return []windows.Handle{req.h}
+	}
+	hs := make([]windows.Handle, len(req.alts))
+	for i, a := range req.alts {
+		hs[i] = a.h
+	}
+	return hs
+}
+
+// closeHandles 关闭 req 已经打开的所有句柄。
+func (req *request) closeHandles() {
+	for _, h := range req.handles() {
+		if h != 0 {
+			windows.CloseHandle(h)
+			openHandles.Add(-1)
+		}
+	}
+}
+
+// knowsAny 表明 w 上是否已经有指向 names 中任意一个对象的请求。调用者必须持有 pool.mu。
+func (w *worker) knowsAny(names []string) bool {
+	for _, name := range names {
+		if w.names[objectKey(name)] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// avoids 表明 req 是否不能交给 w 处理。
+func (req *request) avoids(w *worker) bool {
+	for _, c := range req.avoid {
+		if c == w {
+			return true
+		}
+	}
+	return false
+}
+
+// acquireObject 将创建并等待 obj 的请求交给 worker，并等待结果。
+// done 不为 nil 时，等待会在 done 被关闭后中止并返回 ErrCanceled。
+// 取消通过唤醒事件打断 worker 的 WaitForMultipleObjects，worker 随即关闭请求的句柄，空闲时退出并销毁线程，不会留下阻塞中的线程。
+func acquireObject(obj object, name string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	return acquireRequest(&request{obj: obj, name: name}, name, timeout, done)
+}
+
+// acquireAnyObject 与 acquireObject 相同，但等待 names 对应的对象中的任意一个，一并返回获得的对象在 names 中的下标。
+// names 不能超过 maxWorkerWaits 个，也不能包含指向同一个对象的名字。
+func acquireAnyObject(obj object, names []string, timeout time.Duration, done <-chan struct{}) (*Releaser, int, error) {
+	req := &request{obj: obj, alts: make([]alt, len(names))}
+	for i, name := range names {
+		req.alts[i].name = name
+	}
+	r, err := acquireRequest(req, strings.Join(names, "|"), timeout, done)
+	if err != nil {
+		return nil, -1, err
+	}
+	return r, req.index, nil
+}
+
+// acquireRequest 提交 req 并等待结果。label 是 PhaseBlocked 等事件中使用的名字。
+func acquireRequest(req *request, label string, timeout time.Duration, done <-chan struct{}) (*Releaser, error) {
+	req.reply = make(chan result, 1)
+	if timeout != waitForever {
+		req.deadline = time.Now().Add(timeout)
+	}
+	if observing() {
+		req.blocked = make(chan struct{})
+	}
+	blocked := req.blocked
+	if err := submit(req); err != nil {
+		return nil, err
+	}
+
+	var res result
+	var blockedAt time.Time
+wait:
+	for {
+		select {
+		case <-blocked:
+			blocked = nil
+			blockedAt = time.Now()
+			notify(label, PhaseBlocked, 0, nil)
+		case res = <-req.reply:
+			break wait
+		case <-done:
+			req.abort()
+			res = <-req.reply
+			if res.err == nil {
+				// 取消生效前已经获得了锁。
+				_ = req.release()
+				res.err = ErrCanceled
+			}
+			break wait
+		}
+	}
+	if !blockedAt.IsZero() {
+		notify(label, PhaseUnblocked, time.Since(blockedAt), nil)
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	return &Releaser{
+		isAbandoned: res.abandoned,
+		created:     res.created,
+		handle:      uintptr(req.h),
+		lease:       &lease{release: req.release},
+	}, nil
+}
+
+// release 在持有 req 的 worker 的线程上释放锁，并等待结果。
+func (req *request) release() error {
+	ch := make(chan error, 1)
+	req.w.push(func() { ch <- req.w.release(req) })
+	return <-ch
+}
+
+// newWorker 创建一个 worker 并启动它的协程。
+func newWorker() (*worker, error) {
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-createeventw
+	wake, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &worker{wake: wake, names: make(map[string]int)}
+	go w.run()
+	return w, nil
+}
+
+// push 将 cmd 交给 worker 在其线程上执行。worker 已经退出时丢弃 cmd。
+func (w *worker) push(cmd func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.queue = append(w.queue, cmd)
+	_ = windows.SetEvent(w.wake)
+}
+
+func (w *worker) run() {
+	// windows mutex 必须在同一个线程中操作。go 协程调度会导致线程切换，从而产生死锁。
+	// 协程退出时没有调用 UnlockOSThread，线程会随之销毁。
+	runtime.LockOSThread()
+
+	handles := make([]windows.Handle, 0, maximumWaitObjects)
+	slots := make([]slot, 0, maxWorkerWaits)
+	for {
+		handles = append(handles[:0], w.wake)
+		slots = slots[:0]
+		for p, req := range w.pending {
+			for i, h := range req.handles() {
+				handles = append(handles, h)
+				slots = append(slots, slot{p, i})
+			}
+		}
+
+		// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-waitformultipleobjects
+		rt, err := windows.WaitForMultipleObjects(handles, false, w.nextTimeout())
+		if rt == windows.WAIT_FAILED {
+			// 无法得知是哪个句柄导致了失败，所有等待中的请求都以该错误结束。
+			err = waitFailed(err)
+			for len(w.pending) > 0 {
+				w.finish(0, result{err: err})
+			}
+		}
+
+		n := uint32(len(handles))
+		switch {
+		case rt == windows.WAIT_FAILED:
+		case rt == windows.WAIT_OBJECT_0:
+			w.mu.Lock()
+			queue := w.queue
+			w.queue = nil
+			w.mu.Unlock()
+			for _, cmd := range queue {
+				cmd()
+			}
+		case rt > windows.WAIT_OBJECT_0 && rt < windows.WAIT_OBJECT_0+n:
+			w.acquired(slots[rt-windows.WAIT_OBJECT_0-1], false)
+		case rt > windows.WAIT_ABANDONED && rt < windows.WAIT_ABANDONED+n:
+			w.acquired(slots[rt-windows.WAIT_ABANDONED-1], true)
+		case rt == uint32(windows.WAIT_TIMEOUT):
+		default:
+			err := unexpectedWait(rt)
+			for len(w.pending) > 0 {
+				w.finish(0, result{err: err})
+			}
+		}
+
+		w.expire()
+		if w.exitIfIdle() {
+			return
+		}
+	}
+}
+
+// slot 表明等待的句柄属于等待队列中的第 p 个请求，是该请求的第 i 个对象。
+type slot struct {
+	p, i int
+}
+
+// nextTimeout 返回距离最近的截止时间的毫秒数。没有截止时间时返回 INFINITE。
+func (w *worker) nextTimeout() uint32 {
+	var deadline time.Time
+	for _, req := range w.pending {
+		if !req.deadline.IsZero() && (deadline.IsZero() || req.deadline.Before(deadline)) {
+			deadline = req.deadline
+		}
+	}
+	if deadline.IsZero() {
+		return windows.INFINITE
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return 0
+	}
+	// 向上取整，避免不足一毫秒的剩余时间导致空转。
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms >= windows.INFINITE {
+		return windows.INFINITE - 1
+	}
+	return uint32(ms)
+}
+
+// start 创建 req 的内核对象并尝试立即获得其中一个，无法立即获得时加入等待队列。
+// 被 reassign 转交的请求已经打开了内核对象，不再重复创建。
+func (w *worker) start(req *request) {
+	if len(req.alts) == 0 && req.h == 0 {
+		h, created, err := w.create(req.obj, req.name)
+		if err != nil {
+			w.done(req, result{err: err})
+			return
+		}
+		req.h, req.created = h, created
+	}
+	for i := range req.alts {
+		a := &req.alts[i]
+		if a.h != 0 {
+			continue
+		}
+		h, created, err := w.create(req.obj, a.name)
+		if err != nil {
+			w.done(req, result{err: nameError(a.name, err)})
+			return
+		}
+		a.h, a.created = h, created
+	}
+
+	// https://learn.microsoft.com/zh-cn/windows/win32/api/synchapi/nf-synchapi-waitformultipleobjects
+	handles := req.handles()
+	rt, err := windows.WaitForMultipleObjects(handles, false, 0)
+	n := uint32(len(handles))
+	switch {
+	case rt == windows.WAIT_FAILED:
+		w.done(req, result{err: waitFailed(err)})
+	case rt >= windows.WAIT_OBJECT_0 && rt < windows.WAIT_OBJECT_0+n:
+		w.hold(req, int(rt-windows.WAIT_OBJECT_0), false)
+	case rt >= windows.WAIT_ABANDONED && rt < windows.WAIT_ABANDONED+n:
+		w.hold(req, int(rt-windows.WAIT_ABANDONED), true)
+	case !req.deadline.IsZero() && !time.Now().Before(req.deadline):
+		w.done(req, result{err: errWaitTimeout})
+	default:
+		if req.blocked != nil {
+			close(req.blocked)
+			req.blocked = nil
+		}
+		var leaves []func()
+		for _, name := range req.names() {
+			if name != "" {
+				// 没有名字的请求（FromInheritedHandle）不计入等待者的统计。
+				leaves = append(leaves, enterWaiting(name))
+			}
+		}
+		req.leave = func() {
+			for _, leave := range leaves {
+				leave()
+			}
+		}
+		w.pending = append(w.pending, req)
+	}
+}
+
+// create 创建或打开 obj 中名为 name 的对象，返回它的句柄以及它是否是新创建的。
+func (w *worker) create(obj object, name string) (windows.Handle, bool, error) {
+	h, err := obj.create(windows.StringToUTF16Ptr(name))
+	if err != nil && !errors.Is(err, syscall.ERROR_ALREADY_EXISTS) {
+		return 0, false, createFailed(name, err)
+	}
+	openHandles.Add(1)
+	return h, err == nil, nil
+}
+
+// acquired 处理 s 所指的对象被获得的情况。
+func (w *worker) acquired(s slot, abandoned bool) {
+	w.hold(w.remove(s.p), s.i, abandoned)
+}
+
+// expire 使已经超过截止时间的请求超时。
+func (w *worker) expire() {
+	now := time.Now()
+	for i := 0; i < len(w.pending); {
+		req := w.pending[i]
+		if req.deadline.IsZero() || now.Before(req.deadline) {
+			i++
+			continue
+		}
+		w.finish(i, result{err: errWaitTimeout})
+	}
+}
+
+// cancel 放弃等待 req。req 已经不在等待队列中时什么也不做，调用者会收到它原本的结果。
+func (w *worker) cancel(req *request) {
+	for i, p := range w.pending {
+		if p == req {
+			w.finish(i, result{err: ErrCanceled})
+			return
+		}
+	}
+}
+
+// finish 以失败结束等待队列中第 i 个请求。
+func (w *worker) finish(i int, res result) {
+	w.done(w.remove(i), res)
+}
+
+// remove 将第 i 个请求移出等待队列，并结束它的等待者统计。
+func (w *worker) remove(i int) *request {
+	req := w.pending[i]
+	w.pending = append(w.pending[:i], w.pending[i+1:]...)
+	req.leave()
+	req.leave = nil
+	return req
+}
+
+// hold 将获得了锁的 req 的结果送出，i 为获得的对象在 req 的所有对象中的下标。之后 req 只会被 release 处理。
+// 获得的互斥量在此之前就被 w 的线程持有时，这次获得只是重入，没有与持有者互斥，此时交给 reassign 处理。
+// 对象设置了 onAcquired 时在送出结果之前调用它，它返回错误时释放对象并以该错误结束 req。
+func (w *worker) hold(req *request, i int, abandoned bool) {
+	if h := req.handles()[i]; req.obj.mutant && ownedRecursively(h) {
+		w.reassign(req, h)
+		return
+	}
+	w.choose(req, i)
+	if req.obj.onAcquired != nil {
+		if err := runOnAcquired(req.obj.onAcquired, req.name, abandoned, req.created, uintptr(req.h)); err != nil {
+			_ = req.obj.release(req.h)
+			w.done(req, result{err: err})
+			return
+		}
+	}
+	pool.mu.Lock()
+	w.waiting--
+	pool.mu.Unlock()
+	req.reply <- result{abandoned: abandoned, created: req.created}
+}
+
+// choose 在 req 获得了 alts 中第 i 个对象后关闭其余的句柄，使 req 成为只持有该对象的普通请求。req 不是 AcquireAny 的请求时什么也不做。
+func (w *worker) choose(req *request, i int) {
+	if len(req.alts) == 0 {
+		return
+	}
+	alts := req.alts
+	for j, a := range alts {
+		if j != i {
+			windows.CloseHandle(a.h)
+			openHandles.Add(-1)
+		}
+	}
+	pool.mu.Lock()
+	for j, a := range alts {
+		if j != i {
+			w.forget(a.name)
+			w.waiting--
+		}
+	}
+	pool.mu.Unlock()
+	req.name, req.h, req.created, req.index = alts[i].name, alts[i].h, alts[i].created, i
+	req.alts = nil
+}
+
+// reassign 撤销 req 在 w 上对互斥量 h 的重入，并把它转交给其他 worker 重新等待。req 的句柄保持打开。
+func (w *worker) reassign(req *request, h windows.Handle) {
+	err := req.obj.release(h)
+	names := req.names()
+	pool.mu.Lock()
+	w.waiting -= len(names)
+	for _, name := range names {
+		w.forget(name)
+	}
+	pool.mu.Unlock()
+	if err == nil {
+		req.avoid = append(req.avoid, w)
+		err = submit(req)
+	}
+	if err != nil {
+		req.closeHandles()
+		req.reply <- result{err: err}
+	}
+}
+
+// done 以失败结束 req 并关闭它的句柄。
+func (w *worker) done(req *request, res result) {
+	req.closeHandles()
+	names := req.names()
+	pool.mu.Lock()
+	w.waiting -= len(names)
+	for _, name := range names {
+		w.forget(name)
+	}
+	pool.mu.Unlock()
+	req.reply <- res
+}
+
+// release 释放 req 持有的锁并关闭它的句柄。
+func (w *worker) release(req *request) error {
+	err := req.obj.release(req.h)
+	windows.CloseHandle(req.h)
+	openHandles.Add(-1)
+	pool.mu.Lock()
+	w.forget(req.name)
+	pool.mu.Unlock()
+	return err
+}
+
+// forget 减少 name 的计数。调用者必须持有 pool.mu。
+func (w *worker) forget(name string) {
+	key := objectKey(name)
+	if w.names[key] <= 1 {
+		delete(w.names, key)
+	} else {
+		w.names[key]--
+	}
+}
+
+// exitIfIdle 在 worker 没有任何等待中或持有中的请求时将其移出 pool 并释放唤醒事件。
+func (w *worker) exitIfIdle() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if len(w.names) > 0 {
+		return false
+	}
+	for i, c := range pool.workers {
+		if c == w {
+			pool.workers = append(pool.workers[:i], pool.workers[i+1:]...)
+			break
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	windows.CloseHandle(w.wake)
+	return true
+}